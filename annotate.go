@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// formatKeyValues renders the enabled counts as space separated key=value
+// pairs, e.g. "lines=10 words=50 bytes=300".
+func formatKeyValues(counts Counts, flags Flags) string {
+	names := selectedNames(flags)
+	values := selectedCounts(counts, flags)
+	pairs := make([]string, len(values))
+	for i, value := range values {
+		pairs[i] = fmt.Sprintf("%s=%d", names[i], value)
+	}
+	return strings.Join(pairs, " ")
+}
+
+// formatKVLine formats a -kv result line: the key=value pairs of
+// formatKeyValues followed by file=NAME, the name quoted if it is empty or
+// holds blanks, quotes or '=' characters.
+func formatKVLine(counts Counts, flags Flags, filename string) string {
+	if filename == "" || strings.ContainsAny(filename, " \t\n\"=") {
+		filename = strconv.Quote(filename)
+	}
+	return formatKeyValues(counts, flags) + " file=" + filename
+}
+
+// annotateFile prepends a comment line holding counts to the named file,
+// e.g. "# lines=10 words=50 bytes=300". The new content is written to a
+// temporary file in the same directory which then replaces the original via
+// rename, so the file is never left half-written. Non-regular files are left
+// alone. With -dry-run the line is printed instead of written.
+func annotateFile(filename string, counts Counts, flags Flags) error {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+
+	line := flags.Annotate + " " + formatKeyValues(counts, flags) + "\n"
+	if flags.DryRun {
+		fmt.Printf("%s: would prepend: %s", filename, line)
+		return nil
+	}
+
+	src, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+".gowc-*")
+	if err != nil {
+		return fmt.Errorf("error creating temporary file: %w", err)
+	}
+	// Clean up the temporary file on any failure below; after a successful
+	// rename it no longer exists under this name and Remove is a no-op.
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.WriteString(tmp, line); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing annotation: %w", err)
+	}
+	if _, err := io.Copy(tmp, src); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing annotation: %w", err)
+	}
+	if err := tmp.Chmod(info.Mode().Perm()); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing annotation: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing annotation: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error writing annotation: %w", err)
+	}
+
+	return os.Rename(tmp.Name(), filename)
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"container/heap"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sort"
+)
+
+// Dimensions of the -approx-top count-min sketch. With width w = e/ε and
+// depth d = ln(1/δ), an estimated word count exceeds the true count by more
+// than ε·N, N being the number of words counted, with probability at most
+// δ; it is never lower. These settle on ε = 0.0001 and δ = e^-5 < 0.7%,
+// for about 1 MiB of counters however large the input.
+const (
+	sketchWidth   = 27183
+	sketchDepth   = 5
+	sketchEpsilon = 0.0001
+)
+
+// TopSketch approximates the most frequent words for -approx-top in
+// bounded memory: a count-min sketch estimates the count of every word, and
+// a min-heap keeps the k words with the highest estimates seen so far.
+// Unlike the exact -top, its memory use does not grow with the vocabulary.
+type TopSketch struct {
+	k     int
+	n     int64   // Words added
+	cells []int64 // sketchDepth rows of sketchWidth counters
+	top   topHeap
+}
+
+func newTopSketch(k int) *TopSketch {
+	return &TopSketch{
+		k:     k,
+		cells: make([]int64, sketchDepth*sketchWidth),
+		top:   topHeap{index: make(map[string]int)},
+	}
+}
+
+// sketchHashes derives the two hashes from which the row hashes are made
+// (h1 + i·h2, after Kirsch and Mitzenmacher).
+func sketchHashes(word string) (uint64, uint64) {
+	h := fnv.New64a()
+	h.Write([]byte(word))
+	sum := h.Sum64()
+	return sum & 0xFFFFFFFF, sum>>32 | 1
+}
+
+// Add counts one occurrence of word.
+func (s *TopSketch) Add(word string) {
+	s.n++
+	h1, h2 := sketchHashes(word)
+	estimate := int64(math.MaxInt64)
+	for i := 0; i < sketchDepth; i++ {
+		cell := &s.cells[i*sketchWidth+int((h1+uint64(i)*h2)%sketchWidth)]
+		*cell++
+		if *cell < estimate {
+			estimate = *cell
+		}
+	}
+	s.offer(word, estimate)
+}
+
+// estimate returns the sketch's estimate of the count of word.
+func (s *TopSketch) estimate(word string) int64 {
+	h1, h2 := sketchHashes(word)
+	estimate := int64(math.MaxInt64)
+	for i := 0; i < sketchDepth; i++ {
+		if cell := s.cells[i*sketchWidth+int((h1+uint64(i)*h2)%sketchWidth)]; cell < estimate {
+			estimate = cell
+		}
+	}
+	return estimate
+}
+
+// offer updates the estimate of a word in the heap, or lets the word take
+// the place of the least frequent one if it is now estimated above it.
+func (s *TopSketch) offer(word string, estimate int64) {
+	if pos, ok := s.top.index[word]; ok {
+		s.top.items[pos].Count = estimate
+		heap.Fix(&s.top, pos)
+		return
+	}
+	if len(s.top.items) < s.k {
+		heap.Push(&s.top, wordCount{Word: word, Count: estimate})
+		return
+	}
+	if estimate > s.top.items[0].Count {
+		delete(s.top.index, s.top.items[0].Word)
+		s.top.items[0] = wordCount{Word: word, Count: estimate}
+		s.top.index[word] = 0
+		heap.Fix(&s.top, 0)
+	}
+}
+
+// Merge adds the words counted by other to s. The sketches simply add up;
+// the candidates of both are then estimated afresh from the sum.
+func (s *TopSketch) Merge(other *TopSketch) {
+	s.n += other.n
+	for i, cell := range other.cells {
+		s.cells[i] += cell
+	}
+
+	candidates := make(map[string]bool)
+	for _, w := range s.top.items {
+		candidates[w.Word] = true
+	}
+	for _, w := range other.top.items {
+		candidates[w.Word] = true
+	}
+	s.top = topHeap{index: make(map[string]int)}
+	for word := range candidates {
+		s.offer(word, s.estimate(word))
+	}
+}
+
+// Top returns the estimated most frequent words, most frequent first.
+func (s *TopSketch) Top() []wordCount {
+	words := append([]wordCount(nil), s.top.items...)
+	sort.Slice(words, func(i, j int) bool {
+		if words[i].Count != words[j].Count {
+			return words[i].Count > words[j].Count
+		}
+		return words[i].Word < words[j].Word
+	})
+	return words
+}
+
+// ErrorBound is the overestimate that no count exceeds, except with
+// probability δ (see sketchWidth).
+func (s *TopSketch) ErrorBound() int64 {
+	return int64(math.Ceil(sketchEpsilon * float64(s.n)))
+}
+
+// topHeap is a min-heap of words by count, which also tracks where each
+// word is so its count can be updated in place.
+type topHeap struct {
+	items []wordCount
+	index map[string]int
+}
+
+func (h topHeap) Len() int { return len(h.items) }
+
+func (h topHeap) Less(i, j int) bool {
+	if h.items[i].Count != h.items[j].Count {
+		return h.items[i].Count < h.items[j].Count
+	}
+	return h.items[i].Word > h.items[j].Word // Evict later words first on ties
+}
+
+func (h topHeap) Swap(i, j int) {
+	h.items[i], h.items[j] = h.items[j], h.items[i]
+	h.index[h.items[i].Word] = i
+	h.index[h.items[j].Word] = j
+}
+
+func (h *topHeap) Push(x interface{}) {
+	w := x.(wordCount)
+	h.index[w.Word] = len(h.items)
+	h.items = append(h.items, w)
+}
+
+func (h *topHeap) Pop() interface{} {
+	last := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	delete(h.index, last.Word)
+	return last
+}
+
+// formatApproxTop lists the -approx-top words below a result, one per line,
+// after a heading stating how far the counts may be overestimated.
+func formatApproxTop(s *TopSketch) []string {
+	lines := []string{fmt.Sprintf("    approx top words (counts may be up to %d too high):", s.ErrorBound())}
+	for _, w := range s.Top() {
+		lines = append(lines, fmt.Sprintf("    %*d %s", columnWidth, w.Count, w.Word))
+	}
+	return lines
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// asciiViolation locates the first non-ASCII byte of an input. Line and
+// Column are 1-based; as every character before it on its line is ASCII,
+// the column in characters equals the column in bytes.
+type asciiViolation struct {
+	Offset int64 // Byte offset within the input
+	Line   int64
+	Column int64
+	Byte   byte
+}
+
+// findNonASCII scans reader for the first byte outside the ASCII range,
+// returning nil if there is none.
+func findNonASCII(reader io.Reader) (*asciiViolation, error) {
+	buf := make([]byte, bufferSize)
+	var offset int64
+	line, column := int64(1), int64(1)
+	for {
+		n, err := reader.Read(buf)
+		chunk := buf[:n]
+		for i, b := range chunk {
+			if b < 0x80 {
+				continue
+			}
+			// Work out the position from the part of the chunk before it
+			prefix := chunk[:i]
+			if lines := bytes.Count(prefix, []byte{'\n'}); lines > 0 {
+				line += int64(lines)
+				column = int64(i - bytes.LastIndexByte(prefix, '\n'))
+			} else {
+				column += int64(i)
+			}
+			return &asciiViolation{Offset: offset + int64(i), Line: line, Column: column, Byte: b}, nil
+		}
+
+		offset += int64(n)
+		if lines := bytes.Count(chunk, []byte{'\n'}); lines > 0 {
+			line += int64(lines)
+			column = int64(n - bytes.LastIndexByte(chunk, '\n'))
+		} else {
+			column += int64(n)
+		}
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// printASCII reports whether the named input is pure ASCII and, if not,
+// where its first non-ASCII byte is. found tells the caller to exit non-zero.
+func printASCII(filename string, flags Flags) (found bool, err error) {
+	reader, closer, err := openInput(filename, flags)
+	if err != nil {
+		return false, err
+	}
+	defer closer()
+
+	v, err := findNonASCII(reader)
+	if err != nil {
+		return false, err
+	}
+
+	result := "ascii"
+	if v != nil {
+		result = fmt.Sprintf("non-ascii byte 0x%02x at line %d, column %d (offset %d)",
+			v.Byte, v.Line, v.Column, flags.Offset+v.Offset)
+	}
+	if filename == "-" {
+		fmt.Println(result)
+	} else {
+		fmt.Printf("%s %s\n", result, displayName(filename, flags))
+	}
+	return v != nil, nil
+}
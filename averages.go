@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// averages returns each enabled count of total divided by the number of
+// inputs in it, in the order of selectedCounts, rounded to one decimal
+// place: averages of whole counts need no more to be useful.
+func averages(total Counts, inputs int, flags Flags) []float64 {
+	values := selectedCounts(total, flags)
+	means := make([]float64, len(values))
+	for i, value := range values {
+		means[i] = math.Round(float64(value)/float64(inputs)*10) / 10
+	}
+	return means
+}
+
+// formatMean formats an average for printing, grouping the digits of its
+// whole part with -thousands.
+func formatMean(mean float64, flags Flags) string {
+	s := strconv.FormatFloat(mean, 'f', 1, 64)
+	if !flags.Thousands {
+		return s
+	}
+	whole, _ := strconv.ParseInt(s[:len(s)-2], 10, 64)
+	return formatCount(whole, flags) + s[len(s)-2:]
+}
+
+// formatAverage formats the -averages line printed after the total, in the
+// style of the other result lines: columns by default, a sentence with
+// -prose and key=value pairs with -kv.
+func formatAverage(total Counts, inputs int, flags Flags) string {
+	names := selectedNames(flags)
+	means := averages(total, inputs, flags)
+
+	switch {
+	case flags.Prose:
+		var phrases []string
+		for i, mean := range means {
+			phrases = append(phrases, formatMean(mean, flags)+" "+proseNouns[names[i]][1])
+		}
+		return fmt.Sprintf("On average, an input has %s.", joinProse(phrases))
+	case flags.KV:
+		pairs := make([]string, len(means))
+		for i, mean := range means {
+			pairs[i] = names[i] + "=" + strconv.FormatFloat(mean, 'f', 1, 64)
+		}
+		return strings.Join(pairs, " ") + " file=average"
+	}
+
+	var parts []string
+	widths := columnWidths(flags)
+	for i, mean := range means {
+		formatted := formatMean(mean, flags)
+		if len(formatted) >= widths[i] {
+			formatted = " " + formatted // Keep wide values apart
+		}
+		parts = append(parts, fmt.Sprintf("%*s", widths[i], formatted))
+	}
+	return strings.Join(parts, "") + " average"
+}
+
+// jsonAverages maps the names of the enabled counts to their averages, for
+// the "average" object of the -json document.
+func jsonAverages(total Counts, inputs int, flags Flags) map[string]float64 {
+	names := selectedNames(flags)
+	means := make(map[string]float64, len(names))
+	for i, mean := range averages(total, inputs, flags) {
+		means[names[i]] = mean
+	}
+	return means
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+)
+
+// decodeBase64 returns a reader yielding the decoded contents of reader for
+// -base64-decode, using the URL-safe alphabet (- and _ for + and /) if url
+// is set. Line breaks are ignored. '=' padding is optional at the end, but
+// may only complete a 4-character group: decoding starts afresh after a
+// padded group, so that padded streams can follow each other, and any
+// other '=' is an error.
+func decodeBase64(reader io.Reader, url bool) io.Reader {
+	b := &base64Reader{reader: reader, encoding: base64.StdEncoding, raw: base64.RawStdEncoding}
+	if url {
+		b.encoding, b.raw = base64.URLEncoding, base64.RawURLEncoding
+	}
+	return b
+}
+
+// base64Reader decodes the base64 text read from reader group by group,
+// marking decoding errors, so that invalid input is told apart from a
+// failure to read it.
+type base64Reader struct {
+	reader        io.Reader
+	encoding, raw *base64.Encoding
+
+	buf     []byte // Reusable buffer for reads from reader
+	pending []byte // Base64 text read but not decoded yet, line breaks removed
+	offset  int64  // Position of pending in the text, for error messages
+	out     []byte // Decoded bytes not yet returned to the caller
+	err     error
+}
+
+func (b *base64Reader) Read(p []byte) (int, error) {
+	for len(b.out) == 0 && b.err == nil {
+		if b.buf == nil {
+			b.buf = make([]byte, bufferSize)
+		}
+		n, err := b.reader.Read(b.buf)
+		for _, c := range b.buf[:n] {
+			if c != '\r' && c != '\n' {
+				b.pending = append(b.pending, c)
+			}
+		}
+		if decodeErr := b.decode(err != nil); decodeErr != nil {
+			b.err = decodeErr
+		} else if err != nil {
+			b.err = err
+		}
+	}
+
+	n := copy(p, b.out)
+	b.out = b.out[n:]
+	if len(b.out) == 0 && b.err != nil {
+		return n, b.err
+	}
+	return n, nil
+}
+
+// decode decodes the complete groups of b.pending, up to and including each
+// padded one; at the end of the input, the unpadded group left over too.
+func (b *base64Reader) decode(end bool) error {
+	for {
+		// The text up to the end of the group with the first '=', which
+		// must be the last of its stream, or else all complete groups
+		size := len(b.pending) / 4 * 4
+		if i := bytes.IndexByte(b.pending, '='); i >= 0 && i/4*4+4 <= len(b.pending) {
+			size = i/4*4 + 4
+		} else if end {
+			size = len(b.pending)
+		}
+		if size == 0 {
+			return nil
+		}
+
+		encoding := b.encoding
+		if size%4 != 0 && bytes.IndexByte(b.pending[:size], '=') < 0 {
+			encoding = b.raw // Unpadded at the end of the input
+		}
+		decoded := make([]byte, encoding.DecodedLen(size))
+		n, err := encoding.Decode(decoded, b.pending[:size])
+		b.out = append(b.out, decoded[:n]...)
+		if corrupt, ok := err.(base64.CorruptInputError); ok {
+			return fmt.Errorf("invalid base64 input: %w", base64.CorruptInputError(b.offset+int64(corrupt)))
+		}
+		b.offset += int64(size)
+		b.pending = b.pending[size:]
+	}
+}
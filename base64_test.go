@@ -0,0 +1,49 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestDecodeBase64(t *testing.T) {
+	tests := []struct {
+		in, want string
+		url, bad bool
+	}{
+		{in: "YWJj", want: "abc"},
+		{in: "YQ==", want: "a"},
+		{in: "YWI=", want: "ab"},
+		{in: "YQ", want: "a"},   // Unpadded
+		{in: "YWI", want: "ab"}, // Unpadded
+		{in: "YW\r\nJj\n", want: "abc"},
+		{in: "YQ==\nYg==", want: "ab"}, // Padded streams one after the other
+		{in: "YQ==YWJj", want: "aabc"},
+		{in: "YWJjZA==\nYg", want: "abcdb"},
+		{in: "-_8=", want: "\xfb\xff", url: true},
+		{in: "", want: ""},
+
+		// Misplaced padding
+		{in: "Y===Q", bad: true},
+		{in: "YQ=Yg", bad: true},
+		{in: "YWJj=ZGVm", bad: true},
+		{in: "=YWJ", bad: true},
+		{in: "YQ=", bad: true},
+		{in: "Y", bad: true},
+		{in: "YW*j", bad: true},
+		{in: "-_8=", bad: true}, // The URL-safe alphabet without -base64-url
+	}
+	for _, tt := range tests {
+		// Every byte its own read as well, to split groups across reads
+		for _, r := range []io.Reader{strings.NewReader(tt.in), iotest.OneByteReader(strings.NewReader(tt.in))} {
+			got, err := io.ReadAll(decodeBase64(r, tt.url))
+			switch {
+			case tt.bad && (err == nil || !strings.Contains(err.Error(), "invalid base64 input")):
+				t.Errorf("decoding %q: got %q, %v; want an invalid base64 input error", tt.in, got, err)
+			case !tt.bad && (err != nil || string(got) != tt.want):
+				t.Errorf("decoding %q: got %q, %v; want %q", tt.in, got, err, tt.want)
+			}
+		}
+	}
+}
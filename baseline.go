@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// baseline holds the counts of an earlier run's -json output, loaded by
+// -baseline, by the name each input was printed under; the total is among
+// them under its label. Only the numeric keys of each entry are kept, so
+// that any column the two runs have in common can be compared.
+type baseline struct {
+	entries map[string]map[string]int64
+	order   []string        // Names in the order of the document
+	seen    map[string]bool // Names looked up by the current run
+}
+
+// loadBaseline reads the -baseline file, either a -json document or the
+// array written by -json-stream-array. Entries that recorded an error
+// (-json-errors) have no counts and are left out.
+func loadBaseline(path string) (*baseline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw []json.RawMessage
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(trimmed, &raw)
+	} else {
+		var doc struct {
+			Files []json.RawMessage `json:"files"`
+			Total json.RawMessage   `json:"total"`
+		}
+		err = json.Unmarshal(data, &doc)
+		raw = doc.Files
+		if doc.Total != nil {
+			raw = append(raw, doc.Total)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid baseline: %w", err)
+	}
+
+	b := &baseline{entries: make(map[string]map[string]int64), seen: make(map[string]bool)}
+	for _, entry := range raw {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(entry, &fields); err != nil {
+			return nil, fmt.Errorf("invalid baseline: %w", err)
+		}
+		if _, failed := fields["error"]; failed {
+			continue
+		}
+		var name string
+		json.Unmarshal(fields["filename"], &name) // Missing for standard input
+		counts := make(map[string]int64)
+		for key, value := range fields {
+			var n int64
+			if json.Unmarshal(value, &n) == nil {
+				counts[key] = n
+			}
+		}
+		if _, dup := b.entries[name]; !dup {
+			b.order = append(b.order, name)
+		}
+		b.entries[name] = counts
+	}
+	return b, nil
+}
+
+// BaselineDelta is the change in the printed columns of one result since
+// the -baseline run. Added marks an input the baseline does not have;
+// otherwise Names and Deltas give the columns both runs counted, and the
+// increase (or, negative, the decrease) of each.
+type BaselineDelta struct {
+	Added  bool
+	Names  []string
+	Deltas []int64
+}
+
+// delta compares the counts of the input printed as name with the baseline.
+func (b *baseline) delta(name string, counts Counts, flags Flags) *BaselineDelta {
+	before, ok := b.entries[name]
+	if !ok {
+		return &BaselineDelta{Added: true}
+	}
+	b.seen[name] = true
+	d := &BaselineDelta{}
+	values := selectedCounts(counts, flags)
+	for i, column := range selectedNames(flags) {
+		if value, ok := before[column]; ok {
+			d.Names = append(d.Names, column)
+			d.Deltas = append(d.Deltas, values[i]-value)
+		}
+	}
+	return d
+}
+
+// removed returns the inputs of the baseline, other than its total, that
+// the current run did not count, in the order of the baseline.
+func (b *baseline) removed(totalLabel string) []string {
+	var names []string
+	for _, name := range b.order {
+		if !b.seen[name] && name != totalLabel {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// formatBaseline formats the -baseline line printed below a result.
+func formatBaseline(d *BaselineDelta, flags Flags) string {
+	if d.Added {
+		return "    since baseline: added"
+	}
+	if len(d.Names) == 0 {
+		return "    since baseline: no columns in common"
+	}
+	parts := make([]string, len(d.Names))
+	for i, name := range d.Names {
+		parts[i] = formatDelta(d.Deltas[i], flags) + " " + strings.Replace(name, "_", " ", -1)
+	}
+	return "    since baseline: " + strings.Join(parts, ", ")
+}
+
+// jsonBaseline is the -baseline change of one result: either added, or the
+// change in each column the two runs have in common.
+type jsonBaseline struct {
+	Added bool             `json:"added,omitempty"`
+	Delta map[string]int64 `json:"delta,omitempty"`
+}
+
+func toJSONBaseline(d *BaselineDelta) *jsonBaseline {
+	if d.Added {
+		return &jsonBaseline{Added: true}
+	}
+	jb := &jsonBaseline{Delta: make(map[string]int64, len(d.Names))}
+	for i, name := range d.Names {
+		jb.Delta[name] = d.Deltas[i]
+	}
+	return jb
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// binarySample is how much of the start of an input -skip-binary looks at.
+const binarySample = 8 * 1024
+
+// defaultTextThreshold is the -text-threshold default: like Perl's -T test,
+// an input with more than 30% odd bytes near its start is binary.
+const defaultTextThreshold = 0.7
+
+// binaryFileError is returned for an input -skip-binary skips, with the
+// fraction of text bytes found in its sample.
+type binaryFileError struct {
+	ratio float64
+}
+
+func (e *binaryFileError) Error() string {
+	return fmt.Sprintf("binary file (%.0f%% text)", e.ratio*100)
+}
+
+// textRatio returns the fraction of sample made of bytes that are common in
+// text: printable ASCII, the usual whitespace and control characters (tab,
+// line and form feed, carriage return, backspace and escape), and every
+// byte from 0x80 up, which may be part of UTF-8 or another encoding. An
+// empty sample is all text.
+func textRatio(sample []byte) float64 {
+	if len(sample) == 0 {
+		return 1
+	}
+	text := 0
+	for _, b := range sample {
+		switch {
+		case b >= 0x20 && b != 0x7F,
+			b == '\t', b == '\n', b == '\r', b == '\f', b == '\b', b == 0x1B:
+			text++
+		}
+	}
+	return float64(text) / float64(len(sample))
+}
+
+// checkText looks at the start of reader for -skip-binary and returns a
+// *binaryFileError if less than threshold of it is text. Otherwise it
+// returns a reader yielding the whole input, the sample included.
+func checkText(reader io.Reader, threshold float64) (io.Reader, error) {
+	br := bufio.NewReaderSize(reader, bufferSize)
+	sample, err := br.Peek(binarySample)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, fmt.Errorf("error reading input: %w", err)
+	}
+	if ratio := textRatio(sample); ratio < threshold {
+		return nil, &binaryFileError{ratio: ratio}
+	}
+	return br, nil
+}
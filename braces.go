@@ -0,0 +1,233 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// maxBraceNames bounds how many names the brace expansion of the arguments
+// may yield, so that a typo like {1..9999999999} fails rather than filling
+// up memory.
+const maxBraceNames = 100000
+
+// errTooManyNames ends an expansion past maxBraceNames.
+var errTooManyNames = fmt.Errorf("brace expansion yields more than %d names", maxBraceNames)
+
+// expandArgs applies brace expansion to the file arguments, for shells
+// that leave braces alone: file{1,2}.txt names file1.txt and file2.txt.
+// An argument naming an existing file is taken literally, braces and
+// all; an expanded name that does not exist fails when it is opened, like
+// any other missing file.
+func expandArgs(args []string) ([]string, error) {
+	var expanded []string
+	for _, arg := range args {
+		if !strings.Contains(arg, "{") {
+			expanded = append(expanded, arg)
+			continue
+		}
+		if _, err := os.Lstat(arg); err == nil {
+			expanded = append(expanded, arg)
+			continue
+		}
+		names, err := expandBraces(arg)
+		if err == nil && len(expanded)+len(names) > maxBraceNames {
+			err = errTooManyNames
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", arg, err)
+		}
+		expanded = append(expanded, names...)
+	}
+	return expanded, nil
+}
+
+// expandBraces expands the brace expressions in s like bash does: a list
+// {a,b,c} yields each alternative in turn, and alternatives may hold
+// braces of their own; a sequence {1..5}, {5..1}, {01..10} or {a..e},
+// with an optional step as in {0..10..2}, yields each value, numbers
+// padded with zeros if an end is. Braces holding neither, such as {} or
+// {x}, are kept as they are. It fails with errTooManyNames rather than
+// yield more than maxBraceNames names.
+func expandBraces(s string) ([]string, error) {
+	for i := 0; i < len(s); i++ {
+		if s[i] != '{' {
+			continue
+		}
+		end, commas := matchBrace(s, i)
+		if end < 0 {
+			break // No closing brace for this one, nor for any after it
+		}
+
+		var alternatives []string
+		if len(commas) > 0 {
+			start := i + 1
+			for _, comma := range append(commas, end) {
+				expanded, err := expandBraces(s[start:comma])
+				if err != nil {
+					return nil, err
+				}
+				if len(alternatives)+len(expanded) > maxBraceNames {
+					return nil, errTooManyNames
+				}
+				alternatives = append(alternatives, expanded...)
+				start = comma + 1
+			}
+		} else if seq, ok, err := expandSequence(s[i+1 : end]); err != nil {
+			return nil, err
+		} else if ok {
+			alternatives = seq
+		} else {
+			continue // Literal braces; an expression may still follow inside
+		}
+
+		prefix := s[:i]
+		suffixes, err := expandBraces(s[end+1:])
+		if err != nil {
+			return nil, err
+		}
+		if len(suffixes) > maxBraceNames/len(alternatives) {
+			return nil, errTooManyNames
+		}
+		results := make([]string, 0, len(alternatives)*len(suffixes))
+		for _, alternative := range alternatives {
+			for _, suffix := range suffixes {
+				results = append(results, prefix+alternative+suffix)
+			}
+		}
+		return results, nil
+	}
+	return []string{s}, nil
+}
+
+// matchBrace returns the index of the brace closing the one at s[open], or
+// -1 if it is not closed, and the indexes of the commas it holds at its
+// own level.
+func matchBrace(s string, open int) (int, []int) {
+	depth := 0
+	var commas []int
+	for i := open; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i, commas
+			}
+		case ',':
+			if depth == 1 {
+				commas = append(commas, i)
+			}
+		}
+	}
+	return -1, nil
+}
+
+// expandSequence expands the inside of a sequence expression, "1..5" or
+// "a..e" with an optional "..step", reporting false for anything else.
+func expandSequence(body string) ([]string, bool, error) {
+	parts := strings.Split(body, "..")
+	if len(parts) != 2 && len(parts) != 3 {
+		return nil, false, nil
+	}
+	step := int64(1)
+	if len(parts) == 3 {
+		n, err := strconv.ParseInt(parts[2], 10, 64)
+		if err != nil || n == math.MinInt64 { // The latter has no absolute value
+			return nil, false, nil
+		}
+		if n < 0 {
+			n = -n
+		}
+		if n != 0 {
+			step = n
+		}
+	}
+
+	from, errFrom := strconv.ParseInt(parts[0], 10, 64)
+	to, errTo := strconv.ParseInt(parts[1], 10, 64)
+	if errFrom == nil && errTo == nil {
+		width := 0
+		if padded(parts[0]) || padded(parts[1]) {
+			width = len(parts[0])
+			if len(parts[1]) > width {
+				width = len(parts[1])
+			}
+		}
+		values, err := sequence(from, to, step)
+		if err != nil {
+			return nil, false, err
+		}
+		seq := make([]string, len(values))
+		for i, n := range values {
+			seq[i] = padNumber(n, width)
+		}
+		return seq, true, nil
+	}
+
+	if len(parts[0]) == 1 && len(parts[1]) == 1 && isASCIILetter(parts[0][0]) && isASCIILetter(parts[1][0]) {
+		values, _ := sequence(int64(parts[0][0]), int64(parts[1][0]), step) // Never too long
+		seq := make([]string, len(values))
+		for i, n := range values {
+			seq[i] = string(rune(n))
+		}
+		return seq, true, nil
+	}
+	return nil, false, nil
+}
+
+// sequence returns the values from from to to, counting up or down by a
+// positive step, or errTooManyNames if there are more than maxBraceNames.
+// The distance between the ends is taken as unsigned, which holds it even
+// for ends at the opposite limits of int64, and the values never step past
+// to, where they would wrap around.
+func sequence(from, to, step int64) ([]int64, error) {
+	down := from > to
+	distance := uint64(to) - uint64(from)
+	if down {
+		distance = uint64(from) - uint64(to)
+	}
+	if distance/uint64(step) >= maxBraceNames {
+		return nil, errTooManyNames
+	}
+
+	seq := make([]int64, 0, distance/uint64(step)+1)
+	for n := from; ; {
+		seq = append(seq, n)
+		left := uint64(to) - uint64(n)
+		if down {
+			left = uint64(n) - uint64(to)
+		}
+		if left < uint64(step) {
+			return seq, nil
+		}
+		if down {
+			n -= step
+		} else {
+			n += step
+		}
+	}
+}
+
+// padded reports whether a sequence end is written with leading zeros.
+func padded(end string) bool {
+	end = strings.TrimPrefix(end, "-")
+	return len(end) > 1 && end[0] == '0'
+}
+
+// padNumber formats n with at least width characters, zeros after the sign.
+func padNumber(n int64, width int) string {
+	s := strconv.FormatInt(n, 10)
+	sign := ""
+	if n < 0 {
+		sign, s = "-", s[1:]
+		width--
+	}
+	for len(s) < width {
+		s = "0" + s
+	}
+	return sign + s
+}
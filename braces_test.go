@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestExpandBraces(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"plain", []string{"plain"}},
+		{"f{1,2,3}.txt", []string{"f1.txt", "f2.txt", "f3.txt"}},
+		{"a{,b,c{d,e}}", []string{"a", "ab", "acd", "ace"}},
+		{"{a,b}{1,2}", []string{"a1", "a2", "b1", "b2"}},
+		{"f{1..3}", []string{"f1", "f2", "f3"}},
+		{"f{3..1}", []string{"f3", "f2", "f1"}},
+		{"f{01..03}", []string{"f01", "f02", "f03"}},
+		{"f{-1..1}", []string{"f-1", "f0", "f1"}},
+		{"f{0..10..4}", []string{"f0", "f4", "f8"}},
+		{"f{10..0..-4}", []string{"f10", "f6", "f2"}},
+		{"{a..c}", []string{"a", "b", "c"}},
+		{"{}", []string{"{}"}},
+		{"{x}", []string{"{x}"}},
+		{"{x}{1,2}", []string{"{x}1", "{x}2"}},
+		{"open{", []string{"open{"}},
+		{"z{9223372036854775806..9223372036854775807}", []string{"z9223372036854775806", "z9223372036854775807"}},
+		{"z{-9223372036854775807..-9223372036854775808}", []string{"z-9223372036854775807", "z-9223372036854775808"}},
+		{"z{-9223372036854775808..9223372036854775807..9223372036854775807}", []string{"z-9223372036854775808", "z-1", "z9223372036854775806"}},
+	}
+	for _, tt := range tests {
+		got, err := expandBraces(tt.in)
+		if err != nil {
+			t.Errorf("expandBraces(%q): %v", tt.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("expandBraces(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestExpandBracesLimit(t *testing.T) {
+	for _, in := range []string{"x{1..9999999999}", "x{1..1000}{1..1000}", "x{-9223372036854775808..9223372036854775807}"} {
+		if _, err := expandBraces(in); !errors.Is(err, errTooManyNames) {
+			t.Errorf("expandBraces(%q): got error %v, want %v", in, err, errTooManyNames)
+		}
+	}
+
+	// The limit applies to all the arguments together too
+	if _, err := expandArgs([]string{"x{1..60000}", "y{1..60000}"}); !errors.Is(err, errTooManyNames) {
+		t.Errorf("expandArgs: got error %v, want %v", err, errTooManyNames)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// checkpointInterval is how often -checkpoint writes the progress made so
+// far, at most; it is also written when the run ends or is terminated.
+const checkpointInterval = 5 * time.Second
+
+// checkpointData is what a -checkpoint file holds.
+type checkpointData struct {
+	Completed []string `json:"completed"` // Absolute paths of the files counted
+	Files     int      `json:"files"`     // Inputs in the total
+	Total     Counts   `json:"total"`
+}
+
+// checkpointState implements -checkpoint and -resume: it keeps track of the
+// files counted so far along with their running total, and writes them to
+// its file now and then, so that an interrupted run can be resumed where it
+// left off. It is safe for use by the worker pool and a signal handler.
+type checkpointState struct {
+	path string
+
+	mu        sync.Mutex
+	completed map[string]bool
+	files     int
+	total     Counts
+	saved     time.Time // When the file was last written
+	resumed   []byte    // The file as loaded by -resume, for resumedTotal
+}
+
+// openCheckpoint starts a checkpoint at path. With resume the progress it
+// holds is loaded first; a missing file just means there is none yet.
+func openCheckpoint(path string, resume bool) (*checkpointState, error) {
+	c := &checkpointState{path: path, completed: make(map[string]bool), saved: time.Now()}
+	if !resume {
+		return c, nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var saved checkpointData
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("%s: invalid checkpoint: %w", path, err)
+	}
+	for _, name := range saved.Completed {
+		c.completed[name] = true
+	}
+	c.files, c.total, c.resumed = saved.Files, saved.Total, data
+	return c, nil
+}
+
+// resumedTotal returns a copy of the running total loaded by -resume, and
+// the number of inputs in it, for the new run to continue from.
+func (c *checkpointState) resumedTotal() (Counts, int) {
+	var saved checkpointData
+	if c.resumed != nil {
+		json.Unmarshal(c.resumed, &saved) // Decoded once already, so it cannot fail
+	}
+	return saved.Total, saved.Files
+}
+
+// done reports whether filename was counted by the run being resumed.
+func (c *checkpointState) done(filename string) bool {
+	key, err := filepath.Abs(filename)
+	if err != nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.completed[key]
+}
+
+// record notes that filename has been counted, adding its counts to the
+// running total (counts is nil for a file skipped by -dedup), and writes
+// the checkpoint if the last write is long enough ago.
+func (c *checkpointState) record(filename string, counts *Counts) error {
+	key, err := filepath.Abs(filename)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.completed[key] = true
+	if counts != nil {
+		if err := c.total.Merge(*counts); err != nil {
+			return err
+		}
+		c.files++
+	}
+	if time.Since(c.saved) < checkpointInterval {
+		return nil
+	}
+	return c.saveLocked()
+}
+
+// save writes the checkpoint now.
+func (c *checkpointState) save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.saveLocked()
+}
+
+func (c *checkpointState) saveLocked() error {
+	data := checkpointData{Completed: make([]string, 0, len(c.completed)), Files: c.files, Total: c.total}
+	for name := range c.completed {
+		data.Completed = append(data.Completed, name)
+	}
+	sort.Strings(data.Completed)
+	out, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	c.saved = time.Now()
+	return writeFileAtomic(c.path, append(out, '\n'))
+}
+
+// saveOnSignal makes an interrupt or SIGTERM write the checkpoint before
+// the process exits, so that nothing counted so far is lost.
+func (c *checkpointState) saveOnSignal() {
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-stop
+		if err := c.save(); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s: %v\n", os.Args[0], c.path, err)
+		} else {
+			fmt.Fprintf(os.Stderr, "%s: %v: progress saved to %s\n", os.Args[0], sig, c.path)
+		}
+		os.Exit(1)
+	}()
+}
+
+// remove deletes the checkpoint once the run it describes is complete.
+func (c *checkpointState) remove() error {
+	err := os.Remove(c.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
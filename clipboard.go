@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// clipboardName is the name -clipboard input is reported under.
+const clipboardName = "(clipboard)"
+
+// clipboardCommand returns the command printing the text on the system
+// clipboard. There is no clipboard API in the standard library, so this
+// relies on the tool each platform provides: pbpaste on macOS, PowerShell
+// on Windows, and on other Unix systems wl-paste under Wayland or else
+// xclip or xsel under X11, whichever is installed.
+func clipboardCommand() (*exec.Cmd, error) {
+	switch runtime.GOOS {
+	case "darwin":
+		return exec.Command("pbpaste"), nil
+	case "windows":
+		return exec.Command("powershell", "-NoProfile", "-Command", "Get-Clipboard -Raw"), nil
+	case "linux", "freebsd", "openbsd", "netbsd", "dragonfly":
+		candidates := [][]string{
+			{"xclip", "-selection", "clipboard", "-o"},
+			{"xsel", "--clipboard", "--output"},
+		}
+		if os.Getenv("WAYLAND_DISPLAY") != "" {
+			candidates = append([][]string{{"wl-paste", "--no-newline"}}, candidates...)
+		}
+		var names []string
+		for _, args := range candidates {
+			if _, err := exec.LookPath(args[0]); err == nil {
+				return exec.Command(args[0], args[1:]...), nil
+			}
+			names = append(names, args[0])
+		}
+		return nil, fmt.Errorf("reading the clipboard needs one of %s installed", strings.Join(names, ", "))
+	}
+	return nil, errors.New("reading the clipboard is not supported on " + runtime.GOOS)
+}
+
+// countClipboard counts the text on the system clipboard for -clipboard.
+func countClipboard(flags Flags) (Counts, error) {
+	cmd, err := clipboardCommand()
+	if err != nil {
+		return Counts{}, err
+	}
+	piped, err := startPiped(cmd, "clipboard command "+cmd.Args[0])
+	if err != nil {
+		return Counts{}, err
+	}
+	defer piped.Close()
+	return countOpened(piped, clipboardName, flags)
+}
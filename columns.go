@@ -0,0 +1,61 @@
+package main
+
+import "fmt"
+
+// columnLetters maps the letters of a -column-order spec to the columns
+// they stand for: the wc options for lines, words, characters and bytes,
+// with b as an alias for c, and g for grapheme clusters.
+var columnLetters = map[rune]string{
+	'l': "lines",
+	'w': "words",
+	'g': "graphemes",
+	'm': "chars",
+	'c': "bytes",
+	'b': "bytes",
+}
+
+// parseColumnOrder returns the column names a -column-order spec lists, in
+// its order.
+func parseColumnOrder(spec string) ([]string, error) {
+	var order []string
+	seen := make(map[string]bool)
+	for _, letter := range spec {
+		name, ok := columnLetters[letter]
+		if !ok {
+			return nil, fmt.Errorf("unknown column %q in column order %q (want l, w, g, m, c or b)", letter, spec)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("column %s given twice in column order %q", name, spec)
+		}
+		seen[name] = true
+		order = append(order, name)
+	}
+	return order, nil
+}
+
+// columnOrder returns the indexes into names, the enabled columns in their
+// canonical order, in the order -column-order prints them: the columns the
+// spec lists first, as it lists them, then the others as usual. A column
+// the spec lists is not enabled by that; if it is not, it is left out.
+func columnOrder(names []string, spec string) []int {
+	order, _ := parseColumnOrder(spec) // Checked by Flags.validate
+	index := make(map[string]int, len(names))
+	for i, name := range names {
+		index[name] = i
+	}
+
+	perm := make([]int, 0, len(names))
+	placed := make(map[int]bool, len(names))
+	for _, name := range order {
+		if i, ok := index[name]; ok {
+			perm = append(perm, i)
+			placed[i] = true
+		}
+	}
+	for i := range names {
+		if !placed[i] {
+			perm = append(perm, i)
+		}
+	}
+	return perm
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// compareFiles counts two files and prints their counts, followed by the
+// change from the first to the second in each enabled column: a line with
+// the signed difference (second minus first) and one with the change as
+// a percentage of the first file's count.
+func compareFiles(before, after string, flags Flags) error {
+	beforeCounts, err := countFile(before, flags)
+	if err != nil {
+		return fmt.Errorf("%s: %w", before, err)
+	}
+	afterCounts, err := countFile(after, flags)
+	if err != nil {
+		return fmt.Errorf("%s: %w", after, err)
+	}
+
+	if flags.Headers {
+		fmt.Println(formatHeader(flags))
+	}
+	fmt.Println(formatOutput(beforeCounts, flags, displayName(before, flags)))
+	fmt.Println(formatOutput(afterCounts, flags, displayName(after, flags)))
+	deltas, changes := formatComparison(beforeCounts, afterCounts, flags)
+	fmt.Println(deltas)
+	fmt.Println(changes)
+	return nil
+}
+
+// formatComparison formats the delta and change lines of -compare, in the
+// columns of formatOutput.
+func formatComparison(before, after Counts, flags Flags) (string, string) {
+	var deltas, changes []string
+	widths := columnWidths(flags)
+	afterValues := selectedCounts(after, flags)
+	for i, value := range selectedCounts(before, flags) {
+		delta := afterValues[i] - value
+		deltas = append(deltas, formatColumn(formatDelta(delta, flags), widths[i]))
+		if value == 0 {
+			// A change from nothing has no meaningful percentage
+			changes = append(changes, formatColumn("-", widths[i]))
+			continue
+		}
+		percent := float64(delta) / float64(value) * 100
+		changes = append(changes, formatColumn(fmt.Sprintf("%+.1f%%", percent), widths[i]))
+	}
+	return strings.Join(deltas, "") + " delta", strings.Join(changes, "") + " change"
+}
+
+// formatDelta formats a difference between two counts with an explicit
+// sign, so that a decrease cannot be mistaken for an increase.
+func formatDelta(delta int64, flags Flags) string {
+	switch {
+	case delta > 0:
+		return "+" + formatCount(delta, flags)
+	case delta < 0:
+		return "-" + formatCount(-delta, flags)
+	}
+	return "0"
+}
@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestFormatComparison(t *testing.T) {
+	flags := Flags{ShowLines: true, ShowWords: true, ShowBytes: true}
+	tests := []struct {
+		before, after  Counts
+		delta, changes string
+	}{
+		{
+			Counts{Lines: 10, Words: 100, Bytes: 1000},
+			Counts{Lines: 5, Words: 150, Bytes: 1000},
+			"      -5     +50       0 delta",
+			"  -50.0%  +50.0%   +0.0% change",
+		},
+		// Values as wide as their column are still kept apart
+		{
+			Counts{Lines: 1, Words: 1, Bytes: 0},
+			Counts{Lines: 100000000, Words: 2000, Bytes: 12345678},
+			" +99999999   +1999 +12345678 delta",
+			" +9999999900.0% +199900.0%       - change",
+		},
+	}
+	for _, tt := range tests {
+		delta, changes := formatComparison(tt.before, tt.after, flags)
+		if delta != tt.delta || changes != tt.changes {
+			t.Errorf("formatComparison(%v, %v) =\n%q\n%q\nwant\n%q\n%q", tt.before, tt.after, delta, changes, tt.delta, tt.changes)
+		}
+	}
+}
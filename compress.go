@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+// gzipMagic is the two-byte header every gzip stream starts with.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// decompress returns a reader yielding the decompressed contents of reader
+// if it holds gzip data, recognized by its magic number rather than the file
+// name, so compressed standard input works too. Other input is passed
+// through unchanged.
+func decompress(reader io.Reader) (io.Reader, error) {
+	br := bufio.NewReaderSize(reader, bufferSize)
+	magic, _ := br.Peek(len(gzipMagic)) // A short input simply isn't gzip
+	if !bytes.Equal(magic, gzipMagic) {
+		return br, nil
+	}
+
+	zr, err := gzip.NewReader(br)
+	if err != nil {
+		return nil, fmt.Errorf("error decompressing input: %w", err)
+	}
+	return zr, nil
+}
+
+// compressionRatio returns the compressed size as a percentage of the
+// decompressed size, and false if there is nothing to compare against.
+func compressionRatio(counts Counts) (float64, bool) {
+	if counts.Bytes == 0 {
+		return 0, false
+	}
+	return float64(counts.Compressed) / float64(counts.Bytes) * 100, true
+}
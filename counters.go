@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+
+	"gowc/counters"
+)
+
+// The counting state machine is a counters.Counter whose Result is the
+// Counts value, so that it can be driven like the registered ones.
+var _ counters.Counter = (*counter)(nil)
+
+// Feed implements counters.Counter for the built-in counting state machine.
+func (c *counter) Feed(buf []byte) {
+	c.feed(buf)
+}
+
+// Result implements counters.Counter; it finishes the input and returns
+// its Counts.
+func (c *counter) Result() interface{} {
+	c.finish()
+	return c.counts
+}
+
+// formatCustomResults describes the results of registered counters below a
+// result, one per line.
+func formatCustomResults(results []counters.Result) []string {
+	var lines []string
+	for _, r := range results {
+		lines = append(lines, fmt.Sprintf("    %s: %v", r.Name, r.Value))
+	}
+	return lines
+}
@@ -0,0 +1,94 @@
+// Package counters lets programs add their own metrics to gowc. A Counter
+// is computed in the same single pass over the input as the built-in
+// counts: it is fed every chunk of an input in order, and asked for its
+// result once the input is exhausted.
+//
+// A counter is registered from an init function, typically in a package of
+// its own that gowc imports for its side effect, as database/sql drivers
+// are:
+//
+//	import _ "example.com/digits"
+//
+// gowc then prints each result below the counts of every input, as
+// "name: value", and puts it in the "custom" object of its JSON output.
+package counters
+
+import "sync"
+
+// Counter is a metric computed in a single pass over an input. Feed is
+// called with every chunk in order, and Result once the input is
+// exhausted.
+type Counter interface {
+	Feed(buf []byte)
+	Result() interface{}
+}
+
+// Result is the result of a registered counter for one input.
+type Result struct {
+	Name  string
+	Value interface{}
+}
+
+// registered is a Register entry.
+type registered struct {
+	name       string
+	newCounter func() Counter
+}
+
+var (
+	mu   sync.Mutex
+	list []registered // In registration order
+)
+
+// Register adds a counter under name. newCounter is called for every
+// input, so each input is counted by a fresh Counter. Register must be
+// called before counting starts, i.e. from an init function; it panics if
+// name is already registered or newCounter is nil.
+func Register(name string, newCounter func() Counter) {
+	mu.Lock()
+	defer mu.Unlock()
+	if newCounter == nil {
+		panic("counters: Register of a nil constructor for " + name)
+	}
+	for _, r := range list {
+		if r.name == name {
+			panic("counters: Register called twice for " + name)
+		}
+	}
+	list = append(list, registered{name, newCounter})
+}
+
+// Set is a fresh instance of every registered counter, for one input.
+type Set struct {
+	names    []string
+	counters []Counter
+}
+
+// New returns a Set of fresh instances of the counters registered so far.
+func New() *Set {
+	mu.Lock()
+	defer mu.Unlock()
+	s := &Set{}
+	for _, r := range list {
+		s.names = append(s.names, r.name)
+		s.counters = append(s.counters, r.newCounter())
+	}
+	return s
+}
+
+// Feed feeds buf to every counter of the set.
+func (s *Set) Feed(buf []byte) {
+	for _, c := range s.counters {
+		c.Feed(buf)
+	}
+}
+
+// Results returns the result of every counter of the set, in registration
+// order, or nil if there are none.
+func (s *Set) Results() []Result {
+	var results []Result
+	for i, c := range s.counters {
+		results = append(results, Result{Name: s.names[i], Value: c.Result()})
+	}
+	return results
+}
@@ -0,0 +1,33 @@
+package counters
+
+import "testing"
+
+type byteCount struct{ n int }
+
+func (b *byteCount) Feed(buf []byte)     { b.n += len(buf) }
+func (b *byteCount) Result() interface{} { return b.n }
+
+func TestRegister(t *testing.T) {
+	defer func(saved []registered) { list = saved }(list)
+	list = nil
+
+	Register("bytes", func() Counter { return &byteCount{} })
+	Register("twice", func() Counter { return &byteCount{n: 0} })
+	set := New()
+	set.Feed([]byte("abc"))
+	set.Feed([]byte("de"))
+	results := set.Results()
+	if len(results) != 2 || results[0] != (Result{"bytes", 5}) || results[1] != (Result{"twice", 5}) {
+		t.Errorf("Results() = %v, want [{bytes 5} {twice 5}]", results)
+	}
+	if results := New().Results(); results[0].Value != 0 {
+		t.Errorf("a new set starts from %v, want 0", results[0].Value)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a name twice did not panic")
+		}
+	}()
+	Register("bytes", func() Counter { return &byteCount{} })
+}
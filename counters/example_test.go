@@ -0,0 +1,41 @@
+package counters_test
+
+import (
+	"fmt"
+
+	"gowc/counters"
+)
+
+// digits counts the ASCII digits of an input.
+type digits struct{ n int64 }
+
+func (d *digits) Feed(buf []byte) {
+	for _, b := range buf {
+		if '0' <= b && b <= '9' {
+			d.n++
+		}
+	}
+}
+
+func (d *digits) Result() interface{} { return d.n }
+
+func init() {
+	counters.Register("digits", func() counters.Counter { return &digits{} })
+}
+
+func Example() {
+	// gowc feeds each input in chunks, and the set of every input starts
+	// from zero
+	for _, input := range [][]string{{"room 101, ", "floor 3"}, {"no digits"}} {
+		set := counters.New()
+		for _, chunk := range input {
+			set.Feed([]byte(chunk))
+		}
+		for _, r := range set.Results() {
+			fmt.Printf("%s: %v\n", r.Name, r.Value)
+		}
+	}
+	// Output:
+	// digits: 4
+	// digits: 0
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"gowc/counters"
+)
+
+// vowels counts the ASCII vowels of an input.
+type vowels struct{ n int64 }
+
+func (v *vowels) Feed(buf []byte) {
+	for _, b := range buf {
+		if strings.IndexByte("aeiouAEIOU", b) >= 0 {
+			v.n++
+		}
+	}
+}
+
+func (v *vowels) Result() interface{} { return v.n }
+
+func init() {
+	counters.Register("vowels", func() counters.Counter { return &vowels{} })
+}
+
+func TestRegisteredCounter(t *testing.T) {
+	counts, err := count(strings.NewReader("an example input\n"), Flags{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []counters.Result{{Name: "vowels", Value: int64(6)}}; !reflect.DeepEqual(counts.Custom, want) {
+		t.Errorf("Custom = %v, want %v", counts.Custom, want)
+	}
+	if got, want := formatDetails(counts, Flags{}), []string{"    vowels: 6"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("formatDetails = %q, want %q", got, want)
+	}
+	if got := toJSONCounts(counts, Flags{}, "").Custom; !reflect.DeepEqual(got, map[string]interface{}{"vowels": int64(6)}) {
+		t.Errorf("JSON custom = %v, want map[vowels:6]", got)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+)
+
+// digestReader hashes everything read through it, so -dedup can recognize
+// identical inputs without reading them twice.
+type digestReader struct {
+	reader io.Reader
+	hash   hash.Hash
+}
+
+func newDigestReader(reader io.Reader) *digestReader {
+	return &digestReader{reader: reader, hash: sha256.New()}
+}
+
+func (d *digestReader) Read(p []byte) (int, error) {
+	n, err := d.reader.Read(p)
+	d.hash.Write(p[:n]) // Writing to a hash never fails
+	return n, err
+}
+
+// digest returns the hex SHA-256 of the bytes read so far.
+func (d *digestReader) digest() string {
+	return hex.EncodeToString(d.hash.Sum(nil))
+}
+
+// dedupSet remembers the content digests of the inputs counted so far for
+// -dedup, and how many inputs were skipped as duplicates of them.
+type dedupSet struct {
+	seen    map[string]string // Digest -> name of the first input with it
+	skipped int
+}
+
+func newDedupSet() *dedupSet {
+	return &dedupSet{seen: make(map[string]string)}
+}
+
+// duplicate reports whether an input with the given digest was seen before,
+// returning the name of that first input. Otherwise name is remembered as
+// the first input with this content.
+func (d *dedupSet) duplicate(digest, name string) (string, bool) {
+	if first, ok := d.seen[digest]; ok {
+		d.skipped++
+		return first, true
+	}
+	d.seen[digest] = name
+	return "", false
+}
@@ -0,0 +1,74 @@
+package main
+
+import "sync"
+
+// dirSummary sums up the counts of the files found below each argument for
+// -dir-summary, which prints one line per argument, like du, rather than
+// one per file.
+//
+// The walk hands out the files, and counting finishes them, possibly in
+// different goroutines when the walk feeds the worker pool directly, so the
+// argument each file was found under is remembered behind a mutex until
+// its counts arrive.
+type dirSummary struct {
+	mu     sync.Mutex
+	roots  []string
+	owners map[string][]int // Path -> arguments it was found under, in order
+	totals []Counts
+	seen   []bool
+}
+
+func newDirSummary(roots []string) *dirSummary {
+	return &dirSummary{
+		roots:  roots,
+		owners: make(map[string][]int),
+		totals: make([]Counts, len(roots)),
+		seen:   make([]bool, len(roots)),
+	}
+}
+
+// enter records that the root'th argument is a directory being walked,
+// which gets its line even if no file is found in it.
+func (d *dirSummary) enter(root int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.seen[root] = true
+}
+
+// assign records that path was found under the root'th argument. The same
+// file named twice, e.g. by overlapping arguments, is assigned twice.
+func (d *dirSummary) assign(path string, root int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.owners[path] = append(d.owners[path], root)
+}
+
+// add adds the counts of path to the argument it was found under; the
+// counts of a path that was never assigned are dropped.
+func (d *dirSummary) add(path string, counts Counts) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	owners := d.owners[path]
+	if len(owners) == 0 {
+		return nil
+	}
+	if len(owners) == 1 {
+		delete(d.owners, path)
+	} else {
+		d.owners[path] = owners[1:]
+	}
+	d.seen[owners[0]] = true
+	return d.totals[owners[0]].Merge(counts)
+}
+
+// each calls report with the summed counts of every directory argument
+// and every file argument that was counted, in the order of the arguments.
+// Files that failed are left out of their argument's line; a file argument
+// that failed gets no line at all, just its error.
+func (d *dirSummary) each(report func(counts Counts, root string)) {
+	for i, root := range d.roots {
+		if d.seen[i] {
+			report(d.totals[i], root)
+		}
+	}
+}
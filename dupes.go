@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// dupeTracker compares every line with the one before it for
+// -adjacent-dupes, like a summary of uniq -c: repeats counts the lines
+// that are identical to the previous line, and longestRun the most
+// identical lines in a row. Lines are compared without their terminator,
+// so a final line lacking one still matches; a line split across chunks
+// is kept until its end arrives.
+type dupeTracker struct {
+	line    []byte // Start of the current line, seen in earlier chunks
+	prev    []byte // The previous complete line
+	hasPrev bool
+	run     int64 // Identical lines in a row, up to the previous line
+
+	repeats    int64
+	longestRun int64
+}
+
+func (d *dupeTracker) feed(buf []byte) {
+	for len(buf) > 0 {
+		i := bytes.IndexByte(buf, '\n')
+		if i < 0 {
+			d.line = append(d.line, buf...)
+			return
+		}
+		if len(d.line) > 0 {
+			d.line = append(d.line, buf[:i]...)
+			d.endLine(d.line)
+			d.line = d.line[:0]
+		} else {
+			d.endLine(buf[:i]) // The whole line is in this chunk
+		}
+		buf = buf[i+1:]
+	}
+}
+
+// endLine compares one line, given without its '\n', with the previous one.
+func (d *dupeTracker) endLine(line []byte) {
+	if d.hasPrev && bytes.Equal(line, d.prev) {
+		d.repeats++
+		d.run++
+	} else {
+		d.prev = append(d.prev[:0], line...)
+		d.hasPrev = true
+		d.run = 1
+	}
+	if d.run > d.longestRun {
+		d.longestRun = d.run
+	}
+}
+
+// finish compares a final line lacking a terminator.
+func (d *dupeTracker) finish() {
+	if len(d.line) > 0 {
+		d.endLine(d.line)
+		d.line = d.line[:0]
+	}
+}
+
+// formatDupes formats the -adjacent-dupes line printed below the counts.
+func formatDupes(counts Counts, flags Flags) string {
+	return fmt.Sprintf("    adjacent duplicates: %s lines repeat the previous line, longest run %s",
+		formatCount(counts.Repeats, flags), formatCount(counts.LongestRun, flags))
+}
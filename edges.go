@@ -0,0 +1,56 @@
+package main
+
+import "fmt"
+
+// edgeBlanks tracks the blank lines, empty or holding only whitespace, at
+// the start and at the end of an input for -edge-blanks. A final line
+// lacking its terminator counts like any other line. An input with nothing
+// but blank lines has all of them counted at both ends.
+type edgeBlanks struct {
+	leading     int64
+	trailing    int64 // Blank lines since the last non-blank one
+	pastLeading bool  // Has a non-blank line been seen?
+	lineBlank   bool  // Is the current line blank so far?
+	lineStarted bool  // Does the current line have any bytes?
+}
+
+func newEdgeBlanks() *edgeBlanks {
+	return &edgeBlanks{lineBlank: true}
+}
+
+func (e *edgeBlanks) add(eol, isSpace bool) {
+	if !eol {
+		e.lineStarted = true
+		if !isSpace {
+			e.lineBlank = false
+		}
+		return
+	}
+	e.endLine()
+}
+
+func (e *edgeBlanks) endLine() {
+	if e.lineBlank {
+		if !e.pastLeading {
+			e.leading++
+		}
+		e.trailing++
+	} else {
+		e.pastLeading = true
+		e.trailing = 0
+	}
+	e.lineBlank, e.lineStarted = true, false
+}
+
+// finish ends a final line without a terminator.
+func (e *edgeBlanks) finish() {
+	if e.lineStarted {
+		e.endLine()
+	}
+}
+
+// formatEdgeBlanks formats the -edge-blanks line printed below the counts.
+func formatEdgeBlanks(counts Counts, flags Flags) string {
+	return fmt.Sprintf("    edge blanks: %s leading, %s trailing",
+		formatCount(counts.LeadingBlanks, flags), formatCount(counts.TrailingBlanks, flags))
+}
@@ -0,0 +1,43 @@
+package main
+
+import "unicode"
+
+const zeroWidthJoiner = '\u200d'
+
+// isRegionalIndicator reports whether r is one of the letters that make up
+// flag emoji in pairs, such as U+1F1FA U+1F1F8 for the US flag.
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
+
+// onEmojiRune counts emoji and other symbols (Unicode category So) for
+// -emoji. Sequences that display as a single emoji count once: symbols
+// joined by U+200D ZERO WIDTH JOINER, as in family emoji, and pairs of
+// regional indicators forming a flag. Variation selectors and skin tone
+// modifiers attached to an emoji neither count nor end the sequence.
+func (c *counter) onEmojiRune(r rune) {
+	switch {
+	case r == zeroWidthJoiner:
+		c.emojiJoined = c.inEmoji
+		return
+	case c.inEmoji && (unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Sk, r)):
+		return // U+FE0F and the like, or U+1F3FB..U+1F3FF skin tones
+	case isRegionalIndicator(r):
+		// The second indicator of a pair completes the flag started by the first
+		if !c.emojiJoined && !c.pendingRegional {
+			c.counts.Emoji++
+		}
+		c.pendingRegional = !c.pendingRegional
+		c.inEmoji = true
+	case unicode.Is(unicode.So, r):
+		if !c.emojiJoined {
+			c.counts.Emoji++
+		}
+		c.pendingRegional = false
+		c.inEmoji = true
+	default:
+		c.pendingRegional = false
+		c.inEmoji = false
+	}
+	c.emojiJoined = false
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Supported values for -encoding.
+const (
+	encodingUTF8    = "utf-8"
+	encodingUTF16LE = "utf-16le"
+	encodingUTF16BE = "utf-16be"
+	encodingAuto    = "auto"
+)
+
+// detectSize is how much of an input is inspected to guess its encoding.
+const detectSize = 4096
+
+// detectEncoding guesses the encoding of an input from its first bytes.
+// A byte order mark settles it; otherwise text with NUL bytes concentrated
+// on odd (even) offsets is taken to be UTF-16LE (UTF-16BE), as ASCII-range
+// UTF-16 text would produce. ok is false when nothing matched and the sample
+// is not valid UTF-8 either, in which case UTF-8 is returned as a fallback.
+func detectEncoding(sample []byte) (encoding string, ok bool) {
+	if bom := detectBOM(sample); bom != "" {
+		return bom, true
+	}
+
+	var evenNUL, oddNUL int
+	for i, b := range sample {
+		if b != 0 {
+			continue
+		}
+		if i%2 == 0 {
+			evenNUL++
+		} else {
+			oddNUL++
+		}
+	}
+	// Require NULs in at least half the code units on one parity and
+	// hardly any (under an eighth as many) on the other
+	half := len(sample) / 2
+	if half > 0 {
+		if oddNUL >= half/2 && evenNUL*8 < oddNUL {
+			return encodingUTF16LE, true
+		}
+		if evenNUL >= half/2 && oddNUL*8 < evenNUL {
+			return encodingUTF16BE, true
+		}
+	}
+
+	// The sample may end in the middle of a multi-byte character
+	trimmed := sample
+	for i := len(sample) - 1; i >= 0 && i >= len(sample)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(sample[i]) {
+			if !utf8.FullRune(sample[i:]) {
+				trimmed = sample[:i]
+			}
+			break
+		}
+	}
+	return encodingUTF8, utf8.Valid(trimmed)
+}
+
+// detectBOM returns the encoding whose byte order mark starts sample, or ""
+// if there is none.
+func detectBOM(sample []byte) string {
+	switch {
+	case bytes.HasPrefix(sample, []byte{0xEF, 0xBB, 0xBF}):
+		return encodingUTF8
+	case bytes.HasPrefix(sample, []byte{0xFF, 0xFE}):
+		return encodingUTF16LE
+	case bytes.HasPrefix(sample, []byte{0xFE, 0xFF}):
+		return encodingUTF16BE
+	}
+	return ""
+}
+
+// bomReader passes its input through unchanged, keeping the first bytes
+// for -report-bom to look for a byte order mark in. It only reports the
+// mark; whether one is dropped is up to the decoding as usual.
+type bomReader struct {
+	reader io.Reader
+	head   []byte // Up to the first three bytes
+}
+
+func (b *bomReader) Read(p []byte) (int, error) {
+	n, err := b.reader.Read(p)
+	if want := 3 - len(b.head); want > 0 {
+		if want > n {
+			want = n
+		}
+		b.head = append(b.head, p[:want]...)
+	}
+	return n, err
+}
+
+// bom returns the encoding of the byte order mark read, or "none".
+func (b *bomReader) bom() string {
+	if bom := detectBOM(b.head); bom != "" {
+		return bom
+	}
+	return "none"
+}
+
+// sniffEncoding detects the encoding of the input behind br without
+// consuming any of it.
+func sniffEncoding(br *bufio.Reader) (string, bool) {
+	sample, _ := br.Peek(detectSize) // A short input simply yields a short sample
+	return detectEncoding(sample)
+}
+
+// decodeInput returns a reader yielding the input transcoded to UTF-8, so
+// the counting state machine only ever sees UTF-8. For -encoding auto the
+// encoding is detected per input; warn is called when detection fails and
+// UTF-8 is assumed.
+func decodeInput(reader io.Reader, encoding string, warn func(msg string)) (io.Reader, error) {
+	if encoding == encodingAuto {
+		br := bufio.NewReaderSize(reader, bufferSize)
+		detected, ok := sniffEncoding(br)
+		if !ok {
+			warn("cannot detect encoding, assuming utf-8")
+		}
+		reader, encoding = br, detected
+	}
+
+	switch encoding {
+	case encodingUTF8:
+		return reader, nil
+	case encodingUTF16LE:
+		return newUTF16Reader(reader, false), nil
+	case encodingUTF16BE:
+		return newUTF16Reader(reader, true), nil
+	}
+	return nil, fmt.Errorf("unsupported encoding %q", encoding)
+}
+
+// utf16Reader transcodes a UTF-16 stream to UTF-8. A leading byte order
+// mark is dropped. A surrogate pair split across reads is reassembled; a
+// surrogate without its partner decodes to U+FFFD and is counted in lone.
+type utf16Reader struct {
+	src       io.Reader
+	bigEndian bool
+
+	raw     []byte // Reusable buffer for reads from src
+	odd     byte   // A trailing byte not yet forming a full code unit...
+	hasOdd  bool   // ...if hasOdd is set
+	high    uint16 // A high surrogate waiting for its partner (0 if none)
+	out     []byte // Encoded UTF-8 not yet returned to the caller
+	started bool   // Has the first code unit (a possible BOM) been seen?
+	lone    int64  // Unpaired surrogates
+	err     error
+}
+
+func newUTF16Reader(src io.Reader, bigEndian bool) *utf16Reader {
+	return &utf16Reader{src: src, bigEndian: bigEndian, raw: make([]byte, bufferSize)}
+}
+
+func (u *utf16Reader) Read(p []byte) (int, error) {
+	for len(u.out) == 0 && u.err == nil {
+		n, err := u.src.Read(u.raw)
+		data := u.raw[:n]
+
+		// Complete a code unit whose first byte arrived with the previous read
+		if u.hasOdd && len(data) > 0 {
+			u.decodeUnit(u.unit(u.odd, data[0]))
+			u.hasOdd = false
+			data = data[1:]
+		}
+		for len(data) >= 2 {
+			u.decodeUnit(u.unit(data[0], data[1]))
+			data = data[2:]
+		}
+		if len(data) == 1 {
+			u.odd, u.hasOdd = data[0], true
+		}
+
+		if err != nil {
+			// Whatever is left over at the end can never be completed
+			if u.high != 0 {
+				u.appendRune(utf8.RuneError)
+				u.lone++
+				u.high = 0
+			}
+			if u.hasOdd {
+				u.appendRune(utf8.RuneError) // Half a code unit
+				u.hasOdd = false
+			}
+			u.err = err
+		}
+	}
+
+	n := copy(p, u.out)
+	u.out = u.out[n:]
+	if len(u.out) == 0 && u.err != nil {
+		return n, u.err
+	}
+	return n, nil
+}
+
+// unit assembles a code unit from two bytes in the stream's byte order.
+func (u *utf16Reader) unit(first, second byte) uint16 {
+	if u.bigEndian {
+		return uint16(first)<<8 | uint16(second)
+	}
+	return uint16(second)<<8 | uint16(first)
+}
+
+// appendRune appends the UTF-8 encoding of r to u.out.
+func (u *utf16Reader) appendRune(r rune) {
+	var buf [utf8.UTFMax]byte
+	n := utf8.EncodeRune(buf[:], r)
+	u.out = append(u.out, buf[:n]...)
+}
+
+// decodeUnit appends the UTF-8 encoding of one code unit to u.out, keeping
+// high surrogates back until their low surrogate arrives.
+func (u *utf16Reader) decodeUnit(unit uint16) {
+	if !u.started {
+		u.started = true
+		if unit == 0xFEFF {
+			return // Byte order mark
+		}
+	}
+
+	r := rune(unit)
+	if u.high != 0 {
+		high := rune(u.high)
+		u.high = 0
+		if utf16.IsSurrogate(r) && r >= 0xDC00 {
+			u.appendRune(utf16.DecodeRune(high, r))
+			return
+		}
+		u.appendRune(utf8.RuneError) // Unpaired high surrogate
+		u.lone++
+	}
+
+	switch {
+	case r >= 0xD800 && r < 0xDC00:
+		u.high = unit
+	case r >= 0xDC00 && r < 0xE000:
+		u.appendRune(utf8.RuneError) // Unpaired low surrogate
+		u.lone++
+	default:
+		u.appendRune(r)
+	}
+}
+
+// printEncoding prints the encoding detected for the named input, in the
+// same way -encoding auto would see it.
+func printEncoding(filename string, flags Flags) error {
+	reader, closer, err := openInput(filename, flags)
+	if err != nil {
+		return err
+	}
+	defer closer()
+
+	encoding, ok := sniffEncoding(bufio.NewReaderSize(reader, detectSize))
+	if !ok {
+		encoding = "unknown"
+	}
+	if filename == "-" {
+		fmt.Println(encoding)
+	} else {
+		fmt.Printf("%s %s\n", encoding, displayName(filename, flags))
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"testing/iotest"
+	"unicode/utf16"
+)
+
+// encodeUTF16 encodes s as UTF-16 in the given byte order.
+func encodeUTF16(s string, bigEndian bool) []byte {
+	return encodeUnits(utf16.Encode([]rune(s)), bigEndian)
+}
+
+// encodeUnits writes out UTF-16 code units in the given byte order.
+func encodeUnits(units []uint16, bigEndian bool) []byte {
+	var out []byte
+	for _, unit := range units {
+		if bigEndian {
+			out = append(out, byte(unit>>8), byte(unit))
+		} else {
+			out = append(out, byte(unit), byte(unit>>8))
+		}
+	}
+	return out
+}
+
+func TestUTF16ReaderSurrogatePairs(t *testing.T) {
+	const text = "a😀b\n🎉" // Both emoji are surrogate pairs
+	for _, bigEndian := range []bool{false, true} {
+		data := append(encodeUTF16("\uFEFF", bigEndian), encodeUTF16(text, bigEndian)...)
+
+		// Every byte its own read, and a read boundary between the two
+		// halves of the first pair (BOM, 'a', high surrogate | low surrogate)
+		readers := map[string]io.Reader{
+			"one byte at a time": iotest.OneByteReader(bytes.NewReader(data)),
+			"split pair":         io.MultiReader(bytes.NewReader(data[:6]), bytes.NewReader(data[6:])),
+			"split unit":         io.MultiReader(bytes.NewReader(data[:7]), iotest.HalfReader(bytes.NewReader(data[7:]))),
+		}
+		for name, r := range readers {
+			u := newUTF16Reader(r, bigEndian)
+			got, err := io.ReadAll(iotest.OneByteReader(u))
+			if err != nil {
+				t.Fatalf("big endian %v, %s: %v", bigEndian, name, err)
+			}
+			if string(got) != text || u.lone != 0 {
+				t.Errorf("big endian %v, %s: got %q with %d unpaired surrogates, want %q", bigEndian, name, got, u.lone, text)
+			}
+		}
+	}
+}
+
+func TestUTF16ReaderLoneSurrogates(t *testing.T) {
+	for _, bigEndian := range []bool{false, true} {
+		// A high surrogate followed by a letter, a lone low surrogate, and
+		// a high surrogate at the very end
+		data := encodeUnits([]uint16{0xD83D, 'x', 0xDE00, 'y', 0xD83D}, bigEndian)
+		u := newUTF16Reader(iotest.OneByteReader(bytes.NewReader(data)), bigEndian)
+		got, err := io.ReadAll(u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "�x�y�"; string(got) != want || u.lone != 3 {
+			t.Errorf("big endian %v: got %q with %d unpaired surrogates, want %q with 3", bigEndian, got, u.lone, want)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// execCommand returns the command running command line with the system
+// shell: sh on Unix, cmd.exe on Windows.
+func execCommand(command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/C", command)
+	}
+	return exec.Command("sh", "-c", command)
+}
+
+// countExec runs command for -exec and counts its combined output, which
+// is reported under the command line itself: its standard output and its
+// error output share one pipe, so they are counted together, interleaved
+// as the command wrote them.
+//
+// Unlike -pipe-through, a command exiting with a non-zero status is not an
+// error: what it printed is counted all the same, and the status is kept
+// in ExitStatus for the result to show. Only a command that cannot be run
+// at all, or output that cannot be read, fails the input.
+func countExec(command string, flags Flags) (Counts, error) {
+	output, w, err := os.Pipe()
+	if err != nil {
+		return Counts{}, err
+	}
+	defer output.Close()
+	cmd := execCommand(command)
+	cmd.Stdout, cmd.Stderr = w, w
+	err = cmd.Start()
+	w.Close() // The command has its own copy; ours would keep the pipe open
+	if err != nil {
+		return Counts{}, fmt.Errorf("error starting -exec command: %w", err)
+	}
+
+	counts, err := countOpened(output, command, flags)
+	if err != nil {
+		// Counting stopped early; don't leave the command running
+		cmd.Process.Kill()
+		cmd.Wait()
+		return Counts{}, err
+	}
+
+	var exitErr *exec.ExitError
+	status := 0
+	if err := cmd.Wait(); errors.As(err, &exitErr) {
+		status = exitErr.ExitCode() // -1 if it was killed by a signal
+	} else if err != nil {
+		return Counts{}, fmt.Errorf("error running -exec command: %w", err)
+	}
+	counts.ExitStatus = &status
+	return counts, nil
+}
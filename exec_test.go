@@ -0,0 +1,19 @@
+package main
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestCountExecCombinedOutput(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the command is written for sh")
+	}
+	counts, err := countExec("echo out; echo two words >&2; exit 3", Flags{ShowLines: true, ShowWords: true, MinWordLen: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts.Lines != 2 || counts.Words != 3 || counts.ExitStatus == nil || *counts.ExitStatus != 3 {
+		t.Errorf("counted %d lines and %d words, exit status %v; want 2 and 3, exit status 3", counts.Lines, counts.Words, counts.ExitStatus)
+	}
+}
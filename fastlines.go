@@ -0,0 +1,37 @@
+package main
+
+import "bytes"
+
+// canCountLinesFast reports whether nothing but the line count (and the
+// byte count, which needs no scanning) is wanted from c's input, so that
+// feed can skip the byte-by-byte state machine and let countLines find
+// the newlines instead. That is the case for a plain -l or -lc: every
+// other count, per-line metric or chunk-level tracker, and -any-eol with
+// its '\r' handling, needs the full loop.
+func canCountLinesFast(c *counter) bool {
+	f := c.flags
+	if f.ShowWords || f.JSONAllFields || f.AnyEOL || f.EOLReport || f.NoCollapseDelims {
+		return false
+	}
+	if c.countChars || c.splitBytes || c.trackLines || c.measureWidth || c.trackWords || c.decodeRunes {
+		return false
+	}
+	return c.preview == nil && c.substr == nil && c.patterns == nil && c.utf8 == nil && c.matches == nil && c.dupes == nil && c.wordLengths == nil && c.edges == nil &&
+		c.fields == nil && c.column == nil && c.counts.Indents == nil
+}
+
+// countLines returns the number of '\n' bytes in buf. bytes.IndexByte is
+// implemented in assembly on the common platforms, scanning many bytes per
+// instruction, which makes this several times faster than the per-byte
+// loop with its unicode.IsSpace call on every byte.
+func countLines(buf []byte) int64 {
+	var n int64
+	for {
+		i := bytes.IndexByte(buf, '\n')
+		if i < 0 {
+			return n
+		}
+		n++
+		buf = buf[i+1:]
+	}
+}
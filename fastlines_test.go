@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// benchmarkText returns about 1 MiB of English-like lines, for the
+// benchmarks to count.
+func benchmarkText() []byte {
+	line := "The quick brown fox jumps over the lazy dog, again and again.\n"
+	return bytes.Repeat([]byte(line), (1<<20)/len(line))
+}
+
+// linesCounter returns a counter for -l, on the fast path or not.
+func linesCounter(fast bool) *counter {
+	c := newCounter(Flags{ShowLines: true})
+	c.fastLines = fast
+	return c
+}
+
+func TestFastLines(t *testing.T) {
+	if !linesCounter(true).fastLines || !canCountLinesFast(newCounter(Flags{ShowLines: true})) {
+		t.Fatal("-l does not count lines on the fast path")
+	}
+	for _, text := range []string{"", "no newline", "a\nb\n", "\n\n\r\n", strings.Repeat("x\n", 1000)} {
+		fast, generic := linesCounter(true), linesCounter(false)
+		fast.Feed([]byte(text))
+		generic.Feed([]byte(text))
+		f, g := fast.Result().(Counts), generic.Result().(Counts)
+		if f.Lines != g.Lines || f.Bytes != g.Bytes {
+			t.Errorf("%q: fast path counted %d lines and %d bytes, the state machine %d and %d", text, f.Lines, f.Bytes, g.Lines, g.Bytes)
+		}
+	}
+}
+
+func benchmarkLines(b *testing.B, fast bool) {
+	data := benchmarkText()
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c := linesCounter(fast)
+		for buf := data; len(buf) > 0; {
+			n := bufferSize
+			if n > len(buf) {
+				n = len(buf)
+			}
+			c.Feed(buf[:n])
+			buf = buf[n:]
+		}
+		c.Result()
+	}
+}
+
+// BenchmarkFastLines and BenchmarkGenericLines count the same lines with
+// countLines and with the byte-by-byte state machine.
+func BenchmarkFastLines(b *testing.B)    { benchmarkLines(b, true) }
+func BenchmarkGenericLines(b *testing.B) { benchmarkLines(b, false) }
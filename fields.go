@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// FieldStats summarizes the number of fields per line for -fields. Truly
+// empty lines are not records and are left out.
+type FieldStats struct {
+	Lines int64 // Lines with at least one byte
+	Min   int64 // Fewest fields on any of them
+	Max   int64 // Most fields on any of them
+}
+
+// add records a line with n fields.
+func (s *FieldStats) add(n int64) {
+	if s.Lines == 0 || n < s.Min {
+		s.Min = n
+	}
+	if s.Lines == 0 || n > s.Max {
+		s.Max = n
+	}
+	s.Lines++
+}
+
+// Merge combines the statistics of other into s.
+func (s *FieldStats) Merge(other *FieldStats) {
+	if other == nil || other.Lines == 0 {
+		return
+	}
+	if s.Lines == 0 || other.Min < s.Min {
+		s.Min = other.Min
+	}
+	if s.Lines == 0 || other.Max > s.Max {
+		s.Max = other.Max
+	}
+	s.Lines += other.Lines
+}
+
+// Consistent reports whether every line has the same number of fields, as
+// a well-formed table would.
+func (s *FieldStats) Consistent() bool {
+	return s.Min == s.Max
+}
+
+// fieldSeparator decodes the -fields or -field-sep argument, which may use Go escape
+// sequences such as \t (most shells make a literal tab awkward to type).
+func fieldSeparator(arg string) (string, error) {
+	sep := arg
+	if strings.Contains(arg, `\`) {
+		var err error
+		if sep, err = strconv.Unquote(`"` + arg + `"`); err != nil {
+			return "", fmt.Errorf("invalid field separator: %s", arg)
+		}
+	}
+	if sep == "" {
+		return "", errors.New("empty field separator")
+	}
+	return sep, nil
+}
+
+// fieldCounter counts the separators on each line as the bytes go by. A
+// multi-byte separator is matched with the Knuth-Morris-Pratt failure
+// function, so no part of the line needs to be kept; occurrences do not
+// overlap, just as when the line is split.
+//
+// For -count-column it also collects the bytes of one field per line
+// (column, counted from 0, or -1 for none) and counts its non-empty values,
+// with -unique also the distinct ones.
+type fieldCounter struct {
+	sep     []byte
+	fail    []int // fail[i]: length of the longest proper border of sep[:i+1]
+	matched int   // Bytes of sep matched at the current position
+	seps    int64 // Separators on the current line so far
+	empty   bool  // Is the current line still empty?
+	stats   FieldStats
+
+	column   int
+	value    []byte // The column's bytes on the current line so far
+	values   int64
+	distinct map[string]bool // nil unless distinct values are counted
+}
+
+func newFieldCounter(sep string, column int, unique bool) *fieldCounter {
+	f := &fieldCounter{sep: []byte(sep), fail: make([]int, len(sep)), empty: true, column: column}
+	if unique && column >= 0 {
+		f.distinct = make(map[string]bool)
+	}
+	for i, k := 1, 0; i < len(sep); i++ {
+		for k > 0 && sep[i] != sep[k] {
+			k = f.fail[k-1]
+		}
+		if sep[i] == sep[k] {
+			k++
+		}
+		f.fail[i] = k
+	}
+	return f
+}
+
+// add feeds one byte of the current line, or ends the line if eol is set.
+func (f *fieldCounter) add(char byte, eol bool) {
+	if eol {
+		f.endLine()
+		return
+	}
+	f.empty = false
+	inColumn := int64(f.column) == f.seps
+	if inColumn {
+		f.value = append(f.value, char)
+	}
+	for f.matched > 0 && char != f.sep[f.matched] {
+		f.matched = f.fail[f.matched-1]
+	}
+	if char == f.sep[f.matched] {
+		f.matched++
+	}
+	if f.matched == len(f.sep) {
+		if inColumn {
+			// The whole separator went into value since the field began
+			f.value = f.value[:len(f.value)-len(f.sep)]
+			f.endValue()
+		}
+		f.seps++
+		f.matched = 0 // Start afresh: separators do not overlap
+	}
+}
+
+// endLine records the line just completed and resets the per-line state.
+func (f *fieldCounter) endLine() {
+	if !f.empty {
+		f.stats.add(f.seps + 1)
+	}
+	if int64(f.column) == f.seps {
+		f.value = bytes.TrimSuffix(f.value, []byte{'\r'}) // Part of a "\r\n" terminator
+		f.endValue()
+	}
+	f.matched, f.seps, f.empty = 0, 0, true
+}
+
+// endValue counts the column value just completed, unless it is empty.
+func (f *fieldCounter) endValue() {
+	if len(f.value) > 0 {
+		f.values++
+		if f.distinct != nil {
+			f.distinct[string(f.value)] = true
+		}
+	}
+	f.value = f.value[:0]
+}
+
+// formatFields describes the -fields statistics below a result, flagging
+// ragged tables.
+func formatFields(s *FieldStats) string {
+	switch {
+	case s == nil || s.Lines == 0:
+		return "    fields: no lines"
+	case s.Consistent():
+		return fmt.Sprintf("    fields: %d on every line", s.Min)
+	}
+	return fmt.Sprintf("    fields: %d to %d (ragged)", s.Min, s.Max)
+}
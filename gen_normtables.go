@@ -0,0 +1,237 @@
+//go:build ignore
+// +build ignore
+
+// gen_normtables generates normtables.go, the tables of -nfc and -nfd, from
+// the Unicode character database. It is run by go generate (see norm.go):
+//
+//	go run gen_normtables.go [-ucd URL or directory] [-o normtables.go]
+//
+// The tables hold the canonical combining classes and the canonical
+// decompositions of UnicodeData.txt, and the primary composites: the
+// characters with a canonical decomposition that are not excluded from
+// composition, whether by CompositionExclusions.txt, as singletons or as
+// non-starter decompositions.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// unicodeVersion is the version of the character database the tables are
+// generated from.
+const unicodeVersion = "17.0.0"
+
+var (
+	ucd    = flag.String("ucd", "https://www.unicode.org/Public/"+unicodeVersion+"/ucd", "`URL` or directory of the Unicode character database")
+	output = flag.String("o", "normtables.go", "write the tables to `FILE`")
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("gen_normtables: ")
+	flag.Parse()
+
+	ccc := make(map[rune]uint8)
+	decomp := make(map[rune][]rune)
+	parseUnicodeData(ccc, decomp)
+	excluded := parseExclusions()
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by gen_normtables.go from the Unicode %s character database; DO NOT EDIT.\n\n", unicodeVersion)
+	fmt.Fprintf(&buf, "package main\n\n")
+	writeCCC(&buf, ccc)
+	writeDecompositions(&buf, decomp)
+	writeCompositions(&buf, ccc, decomp, excluded)
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*output, src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// open opens the database file name, from the web or from a directory.
+func open(name string) io.ReadCloser {
+	if strings.HasPrefix(*ucd, "http://") || strings.HasPrefix(*ucd, "https://") {
+		resp, err := http.Get(*ucd + "/" + name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			log.Fatalf("%s/%s: %s", *ucd, name, resp.Status)
+		}
+		return resp.Body
+	}
+	file, err := os.Open(path.Join(*ucd, name))
+	if err != nil {
+		log.Fatal(err)
+	}
+	return file
+}
+
+// eachLine calls fn with the semicolon-separated fields of every line of
+// the database file name, comments and blank lines left out.
+func eachLine(name string, fn func(fields []string)) {
+	file := open(name)
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		fields := strings.Split(line, ";")
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		fn(fields)
+	}
+	if err := scanner.Err(); err != nil {
+		log.Fatalf("%s: %v", name, err)
+	}
+}
+
+// parseCode parses a code point written in hex.
+func parseCode(s string) rune {
+	n, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		log.Fatalf("invalid code point %q", s)
+	}
+	return rune(n)
+}
+
+// parseUnicodeData reads the combining classes and canonical
+// decompositions of UnicodeData.txt. The ranges it gives by their first
+// and last character (CJK ideographs, Hangul syllables and the like) have
+// neither.
+func parseUnicodeData(ccc map[rune]uint8, decomp map[rune][]rune) {
+	eachLine("UnicodeData.txt", func(fields []string) {
+		r := parseCode(fields[0])
+		class, err := strconv.Atoi(fields[3])
+		if err != nil {
+			log.Fatalf("UnicodeData.txt: %U: invalid combining class %q", r, fields[3])
+		}
+		if class != 0 {
+			ccc[r] = uint8(class)
+		}
+		if d := fields[5]; d != "" && !strings.HasPrefix(d, "<") { // Compatibility ones are tagged
+			for _, code := range strings.Fields(d) {
+				decomp[r] = append(decomp[r], parseCode(code))
+			}
+		}
+	})
+}
+
+// parseExclusions reads the characters CompositionExclusions.txt excludes
+// from composition.
+func parseExclusions() map[rune]bool {
+	excluded := make(map[rune]bool)
+	eachLine("CompositionExclusions.txt", func(fields []string) {
+		excluded[parseCode(fields[0])] = true
+	})
+	return excluded
+}
+
+// sortedKeys returns the characters of m in order.
+func sortedKeys(m map[rune][]rune) []rune {
+	var keys []rune
+	for r := range m {
+		keys = append(keys, r)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+func writeCCC(w io.Writer, ccc map[rune]uint8) {
+	var chars []rune
+	for r := range ccc {
+		chars = append(chars, r)
+	}
+	sort.Slice(chars, func(i, j int) bool { return chars[i] < chars[j] })
+
+	fmt.Fprintf(w, "// cccRanges lists the characters with a non-zero canonical combining\n")
+	fmt.Fprintf(w, "// class, as ranges of consecutive characters sharing a class.\n")
+	fmt.Fprintf(w, "var cccRanges = []cccRange{\n")
+	for i := 0; i < len(chars); {
+		lo, hi := chars[i], chars[i]
+		for i++; i < len(chars) && chars[i] == hi+1 && ccc[chars[i]] == ccc[lo]; i++ {
+			hi = chars[i]
+		}
+		fmt.Fprintf(w, "\t{0x%04X, 0x%04X, %d},\n", lo, hi, ccc[lo])
+	}
+	fmt.Fprintf(w, "}\n\n")
+}
+
+// fullDecomposition applies the canonical decompositions to r until none
+// is left.
+func fullDecomposition(r rune, decomp map[rune][]rune) []rune {
+	d, ok := decomp[r]
+	if !ok {
+		return []rune{r}
+	}
+	var full []rune
+	for _, c := range d {
+		full = append(full, fullDecomposition(c, decomp)...)
+	}
+	return full
+}
+
+// quote writes s as a Go string literal, with every character outside
+// printable ASCII escaped.
+func quote(s []rune) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r >= 0x20 && r < 0x7F && r != '"' && r != '\\':
+			b.WriteRune(r)
+		case r <= 0xFFFF:
+			fmt.Fprintf(&b, `\u%04X`, r)
+		default:
+			fmt.Fprintf(&b, `\U%08X`, r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+func writeDecompositions(w io.Writer, decomp map[rune][]rune) {
+	fmt.Fprintf(w, "// decompositions maps each character with a canonical decomposition,\n")
+	fmt.Fprintf(w, "// other than the Hangul syllables, to its full decomposition.\n")
+	fmt.Fprintf(w, "var decompositions = []decomposition{\n")
+	for _, r := range sortedKeys(decomp) {
+		fmt.Fprintf(w, "\t{0x%04X, %s},\n", r, quote(fullDecomposition(r, decomp)))
+	}
+	fmt.Fprintf(w, "}\n\n")
+}
+
+func writeCompositions(w io.Writer, ccc map[rune]uint8, decomp map[rune][]rune, excluded map[rune]bool) {
+	fmt.Fprintf(w, "// compositions lists the primary composites with the pair of characters\n")
+	fmt.Fprintf(w, "// canonical composition forms them from.\n")
+	fmt.Fprintf(w, "var compositions = []composition{\n")
+	for _, r := range sortedKeys(decomp) {
+		d := decomp[r]
+		if len(d) != 2 || excluded[r] || ccc[r] != 0 || ccc[d[0]] != 0 {
+			continue // A singleton, an exclusion or a non-starter decomposition
+		}
+		fmt.Fprintf(w, "\t{0x%04X, 0x%04X, 0x%04X},\n", d[0], d[1], r)
+	}
+	fmt.Fprintf(w, "}\n")
+}
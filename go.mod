@@ -1 +1,3 @@
 module gowc
+
+go 1.27.1
@@ -0,0 +1,135 @@
+package main
+
+import "unicode"
+
+// Grapheme cluster break classes, after the Grapheme_Cluster_Break property
+// of Unicode Standard Annex #29.
+const (
+	gcOther = iota
+	gcCR
+	gcLF
+	gcControl
+	gcExtend
+	gcZWJ
+	gcRegional
+	gcSpacingMark
+	gcL   // Hangul leading consonant (choseong)
+	gcV   // Hangul vowel (jungseong)
+	gcT   // Hangul trailing consonant (jongseong)
+	gcLV  // Hangul syllable without a trailing consonant
+	gcLVT // Hangul syllable with one
+	gcPictographic
+)
+
+// Hangul syllables are laid out algorithmically: 19 leading consonants
+// times 21 vowels times 28 trailing consonants (the first being none).
+const (
+	hangulBase     = 0xAC00
+	hangulLast     = 0xD7A3
+	hangulTrailing = 28
+)
+
+// graphemeClass returns the break class of r. The standard library has no
+// Grapheme_Cluster_Break table, so the classes are derived from general
+// categories, which is close enough for counting: nonspacing and enclosing
+// marks extend a cluster, spacing marks do too, and symbols (So) stand in
+// for Extended_Pictographic, as they do for -emoji. Prepend characters,
+// a handful of rare ones, are treated as ordinary characters.
+func graphemeClass(r rune) int {
+	switch {
+	case r < 0x80:
+		switch {
+		case r == '\r':
+			return gcCR
+		case r == '\n':
+			return gcLF
+		case r < 0x20 || r == 0x7F:
+			return gcControl
+		}
+		return gcOther
+	case r == zeroWidthJoiner:
+		return gcZWJ
+	case r == 0x200C, r >= 0x1F3FB && r <= 0x1F3FF, r >= 0xE0020 && r <= 0xE007F:
+		return gcExtend // ZWNJ, skin tone modifiers and emoji tag characters
+	case isRegionalIndicator(r):
+		return gcRegional
+	case r >= hangulBase && r <= hangulLast:
+		if (r-hangulBase)%hangulTrailing == 0 {
+			return gcLV
+		}
+		return gcLVT
+	case r >= 0x1100 && r <= 0x115F, r >= 0xA960 && r <= 0xA97C:
+		return gcL
+	case r >= 0x1160 && r <= 0x11A7, r >= 0xD7B0 && r <= 0xD7C6:
+		return gcV
+	case r >= 0x11A8 && r <= 0x11FF, r >= 0xD7CB && r <= 0xD7FB:
+		return gcT
+	case unicode.In(r, unicode.Mn, unicode.Me):
+		return gcExtend
+	case unicode.Is(unicode.Mc, r):
+		return gcSpacingMark
+	case unicode.In(r, unicode.Cc, unicode.Cf, unicode.Zl, unicode.Zp):
+		return gcControl
+	case unicode.Is(unicode.So, r):
+		return gcPictographic
+	}
+	return gcOther
+}
+
+// graphemeCounter counts extended grapheme clusters, the user-perceived
+// characters of UAX #29, in a stream of characters: "é" written as 'e'
+// followed by a combining accent is one, and so is a family emoji made of
+// several people joined by U+200D.
+type graphemeCounter struct {
+	count    int64
+	started  bool
+	prev     int  // Class of the previous character
+	inPict   bool // Is the previous character a pictograph, or extends one?
+	pictZWJ  bool // Is it a ZWJ following such a pictograph?
+	regional int  // Regional indicators in a row up to the previous character
+}
+
+// add considers the next character, counting a cluster if one starts there.
+func (g *graphemeCounter) add(r rune) {
+	class := graphemeClass(r)
+	if !g.started || g.breaks(class) {
+		g.count++
+		g.started = true
+	}
+
+	if class == gcRegional {
+		g.regional++
+	} else {
+		g.regional = 0
+	}
+	g.pictZWJ = class == gcZWJ && g.inPict
+	g.inPict = class == gcPictographic || (class == gcExtend && g.inPict)
+	g.prev = class
+}
+
+// breaks applies the boundary rules of UAX #29 between the previous
+// character and one of the given class.
+func (g *graphemeCounter) breaks(class int) bool {
+	prev := g.prev
+	switch {
+	case prev == gcCR && class == gcLF:
+		return false // GB3
+	case prev == gcCR || prev == gcLF || prev == gcControl:
+		return true // GB4
+	case class == gcCR || class == gcLF || class == gcControl:
+		return true // GB5
+	case prev == gcL && (class == gcL || class == gcV || class == gcLV || class == gcLVT):
+		return false // GB6
+	case (prev == gcLV || prev == gcV) && (class == gcV || class == gcT):
+		return false // GB7
+	case (prev == gcLVT || prev == gcT) && class == gcT:
+		return false // GB8
+	case class == gcExtend || class == gcZWJ || class == gcSpacingMark:
+		return false // GB9, GB9a
+	case g.pictZWJ && class == gcPictographic:
+		return false // GB11
+	case prev == gcRegional && class == gcRegional && g.regional%2 == 1:
+		return false // GB12, GB13: flags are pairs
+	}
+	return true // GB999
+}
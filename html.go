@@ -0,0 +1,188 @@
+package main
+
+import (
+	"io"
+)
+
+// States of the tagStripper state machine.
+const (
+	htmlText    = iota // Ordinary text, passed through
+	htmlLT             // Just after a '<' that may or may not open a tag
+	htmlBang           // Just after "<!", which opens a comment if "--" follows
+	htmlTag            // Inside a tag or declaration, up to its closing '>'
+	htmlComment        // Inside <!-- ... -->
+	htmlRawText        // Inside a <script> or <style> element
+)
+
+// tagStripper is a reader that removes HTML markup from the text read
+// through it: tags, comments, and the contents of <script> and <style>
+// elements. It is deliberately simple rather than a full HTML parser (it
+// does not decode entities, for instance), but it streams, keeping its state
+// across reads. A '<' that cannot start a tag, as in "a < b", is kept.
+type tagStripper struct {
+	src   io.Reader
+	state int
+
+	name    []byte // Lowercased name of the current tag, while it is being read
+	naming  bool   // Still reading the tag name?
+	closing bool   // Is the current tag an end tag (</...>)?
+	quote   byte   // Quote character of the attribute value being read, if any
+	prev    byte   // Previous byte inside the tag, to spot "/>"
+	dashes  int    // Dashes matched of a comment's "<!--" opener or "-->" closer
+	endTag  string // End tag that terminates the current raw text element
+	matched int    // Bytes of endTag matched so far
+
+	raw []byte // Reusable buffer for reads from src
+	out []byte // Text not yet returned to the caller
+	err error
+}
+
+func newTagStripper(src io.Reader) *tagStripper {
+	return &tagStripper{src: src, raw: make([]byte, bufferSize)}
+}
+
+func (t *tagStripper) Read(p []byte) (int, error) {
+	// Keep reading while whole chunks are markup, so that a 0, nil result
+	// never reaches the caller
+	for len(t.out) == 0 && t.err == nil {
+		n, err := t.src.Read(t.raw)
+		for _, b := range t.raw[:n] {
+			t.step(b)
+		}
+		if err != nil {
+			if t.state == htmlLT {
+				t.out = append(t.out, '<') // A lone '<' at the very end is just text
+			}
+			t.err = err
+		}
+	}
+
+	n := copy(p, t.out)
+	t.out = t.out[n:]
+	if len(t.out) == 0 && t.err != nil {
+		return n, t.err
+	}
+	return n, nil
+}
+
+// startTag switches to the tag state for a tag whose first byte is b.
+func (t *tagStripper) startTag(b byte, closing bool) {
+	t.state = htmlTag
+	t.name = t.name[:0]
+	t.naming = isASCIILetter(b)
+	t.closing = closing
+	t.quote, t.prev = 0, b
+	if t.naming {
+		t.name = append(t.name, toLowerASCII(b))
+	}
+}
+
+// step advances the state machine by one input byte, appending any text it
+// yields to t.out.
+func (t *tagStripper) step(b byte) {
+	switch t.state {
+	case htmlText:
+		if b == '<' {
+			t.state = htmlLT
+		} else {
+			t.out = append(t.out, b)
+		}
+
+	case htmlLT:
+		switch {
+		case b == '/':
+			t.startTag(b, true)
+			t.naming = true // The name follows the slash
+		case isASCIILetter(b) || b == '?':
+			t.startTag(b, false)
+		case b == '!':
+			t.state = htmlBang
+			t.dashes = 0
+		default:
+			// Not markup after all: keep the '<' and reprocess b as text
+			t.out = append(t.out, '<')
+			t.state = htmlText
+			t.step(b)
+		}
+
+	case htmlBang:
+		if b == '-' {
+			t.dashes++
+			if t.dashes == 2 {
+				t.state = htmlComment
+				t.dashes = 0
+			}
+			return
+		}
+		// A declaration such as <!DOCTYPE html>, which ends like a tag
+		t.startTag(b, false)
+		t.naming = false
+		t.step(b)
+
+	case htmlComment:
+		switch {
+		case b == '-':
+			t.dashes++
+		case b == '>' && t.dashes >= 2:
+			t.state = htmlText
+		default:
+			t.dashes = 0
+		}
+
+	case htmlTag:
+		if t.quote != 0 {
+			if b == t.quote {
+				t.quote = 0
+			}
+			return
+		}
+		if t.naming && (isASCIILetter(b) || len(t.name) > 0 && isASCIIDigit(b)) {
+			t.name = append(t.name, toLowerASCII(b))
+			return
+		}
+		t.naming = false
+		switch b {
+		case '"', '\'':
+			t.quote = b
+		case '>':
+			t.state = htmlText
+			name := string(t.name)
+			if !t.closing && t.prev != '/' && (name == "script" || name == "style") {
+				t.state = htmlRawText
+				t.endTag = "</" + name
+				t.matched = 0
+			}
+		}
+		t.prev = b
+
+	case htmlRawText:
+		switch {
+		case toLowerASCII(b) == t.endTag[t.matched]:
+			t.matched++
+			if t.matched == len(t.endTag) {
+				// Let the tag state swallow the rest of the end tag
+				t.startTag(b, true)
+				t.naming = false
+			}
+		case b == '<':
+			t.matched = 1
+		default:
+			t.matched = 0
+		}
+	}
+}
+
+func isASCIILetter(b byte) bool {
+	return 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z'
+}
+
+func isASCIIDigit(b byte) bool {
+	return '0' <= b && b <= '9'
+}
+
+func toLowerASCII(b byte) byte {
+	if 'A' <= b && b <= 'Z' {
+		return b + ('a' - 'A')
+	}
+	return b
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// trackIndent measures the leading whitespace of each line for
+// -indent-stats. A space is one column wide and a tab advances to the next
+// multiple of -tab-width. The width is recorded at the first other character,
+// so blank lines, which have no indentation to speak of, are left out.
+func (c *counter) trackIndent(char byte, eol bool) {
+	switch {
+	case eol:
+		c.indenting, c.indent = true, 0
+	case !c.indenting:
+	case char == ' ':
+		c.indent++
+	case char == '\t':
+		c.indent += int64(c.flags.TabWidth) - c.indent%int64(c.flags.TabWidth)
+	case char == '\r':
+		// Part of a "\r\n" terminator, or invisible anyway
+	default:
+		c.counts.Indents[c.indent]++
+		c.indenting = false
+	}
+}
+
+// formatIndentStats describes the -indent-stats histogram, as the number of
+// lines at each indentation width in increasing order of width.
+func formatIndentStats(indents map[int64]int64) string {
+	if len(indents) == 0 {
+		return "    indent: no lines"
+	}
+	widths := make([]int64, 0, len(indents))
+	for width := range indents {
+		widths = append(widths, width)
+	}
+	sort.Slice(widths, func(i, j int) bool { return widths[i] < widths[j] })
+
+	parts := []string{"    indent:"}
+	for _, width := range widths {
+		parts = append(parts, fmt.Sprintf("%d=%d", width, indents[width]))
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,397 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// jsonCounts is the JSON representation of a Counts value. Fields are pointers
+// so that columns which were not requested can be left out of the document.
+type jsonCounts struct {
+	Filename  string `json:"filename,omitempty"`
+	Error     string `json:"error,omitempty"` // Instead of any counts, with -json-errors
+	Lines     *int64 `json:"lines,omitempty"`
+	Words     *int64 `json:"words,omitempty"`
+	Chars     *int64 `json:"chars,omitempty"`
+	Graphemes *int64 `json:"graphemes,omitempty"`
+	Bytes     *int64 `json:"bytes,omitempty"`
+
+	TrulyEmpty     *int64 `json:"truly_empty,omitempty"`
+	WhitespaceOnly *int64 `json:"whitespace_only,omitempty"`
+	Printable      *int64 `json:"printable,omitempty"`
+	Control        *int64 `json:"control,omitempty"`
+
+	WhitespaceBytes *int64 `json:"whitespace_bytes,omitempty"`
+	ContentBytes    *int64 `json:"content_bytes,omitempty"`
+
+	Emoji          *int64 `json:"emoji,omitempty"`
+	Substr         *int64 `json:"substr,omitempty"`
+	Matches        *int64 `json:"matches,omitempty"`
+	Repeats        *int64 `json:"adjacent_dupes,omitempty"`
+	LongestRun     *int64 `json:"longest_run,omitempty"`
+	LeadingBlanks  *int64 `json:"leading_blanks,omitempty"`
+	TrailingBlanks *int64 `json:"trailing_blanks,omitempty"`
+	ColumnValues   *int64 `json:"column_values,omitempty"`
+	ColumnDistinct *int64 `json:"column_distinct,omitempty"`
+	MaxLine        *int64 `json:"max_line,omitempty"`
+	MaxLineBytes   *int64 `json:"max_line_bytes,omitempty"`
+	OverLength     *int64 `json:"over_length,omitempty"`
+	ExitStatus     *int   `json:"exit_status,omitempty"`
+	BOM            string `json:"bom,omitempty"`
+
+	OverLengthLines []int64    `json:"over_length_lines,omitempty"`
+	LongestLines    []LongLine `json:"longest_lines,omitempty"`
+	UniqueWords     *int64     `json:"unique_words,omitempty"`
+	DistinctChars   *int64     `json:"distinct_chars,omitempty"`
+	CharList        *string    `json:"char_list,omitempty"`
+	FilteredWords   *int64     `json:"filtered_words,omitempty"`
+	CappedWords     *int64     `json:"capped_words,omitempty"`
+	SkippedBytes    *int64     `json:"sample_skipped_bytes,omitempty"`
+
+	Baseline *jsonBaseline `json:"baseline,omitempty"`
+
+	EOL         *jsonEOL         `json:"eol,omitempty"`
+	LineStats   *jsonLineStats   `json:"line_stats,omitempty"`
+	WordLengths *jsonWordLengths `json:"word_lengths,omitempty"`
+	Fields      *jsonFields      `json:"fields,omitempty"`
+
+	Readability   *jsonReadability   `json:"readability,omitempty"`
+	SentenceTypes *jsonSentenceTypes `json:"sentence_types,omitempty"`
+
+	TopWords []wordCount `json:"top_words,omitempty"`
+
+	Patterns    []jsonPattern `json:"patterns,omitempty"`
+	PatternHits *int64        `json:"pattern_hits,omitempty"`
+
+	ApproxTopWords []wordCount `json:"approx_top_words,omitempty"`
+
+	UTF8           map[string]jsonUTF8Error `json:"utf8,omitempty"`
+	LoneSurrogates *int64                   `json:"lone_surrogates,omitempty"`
+
+	Indents map[int64]int64  `json:"indent,omitempty"`
+	Scripts map[string]int64 `json:"scripts,omitempty"`
+
+	Compressed *int64   `json:"compressed,omitempty"`
+	Ratio      *float64 `json:"ratio,omitempty"`
+
+	BytesPerLine *float64 `json:"bytes_per_line,omitempty"`
+
+	FirstLine *string `json:"first_line,omitempty"`
+	LastLine  *string `json:"last_line,omitempty"`
+
+	Custom map[string]interface{} `json:"custom,omitempty"`
+}
+
+// jsonEOL is the -eol-report breakdown of line terminators.
+type jsonEOL struct {
+	LF   int64 `json:"lf"`
+	CRLF int64 `json:"crlf"`
+	CR   int64 `json:"cr"`
+}
+
+// jsonUTF8Error is one kind of error in a -utf8-report.
+type jsonUTF8Error struct {
+	Count       int64  `json:"count"`
+	FirstOffset *int64 `json:"first_offset,omitempty"`
+}
+
+// jsonFields is the -fields summary of fields per line.
+type jsonFields struct {
+	Lines      int64 `json:"lines"`
+	Min        int64 `json:"min"`
+	Max        int64 `json:"max"`
+	Consistent bool  `json:"consistent"`
+}
+
+// jsonReadability is the -readability summary; the score is approximate.
+type jsonReadability struct {
+	Sentences             int64   `json:"sentences"`
+	Paragraphs            int64   `json:"paragraphs"`
+	SentencesPerParagraph float64 `json:"sentences_per_paragraph"`
+	WordsPerSentence      float64 `json:"words_per_sentence"`
+	Flesch                float64 `json:"flesch_reading_ease"`
+}
+
+// jsonSentenceTypes is the -sentence-types breakdown.
+type jsonSentenceTypes struct {
+	Periods      int64 `json:"periods"`
+	Exclamations int64 `json:"exclamations"`
+	Questions    int64 `json:"questions"`
+	Unterminated int64 `json:"unterminated"`
+}
+
+// jsonPattern is the count of one -patterns pattern.
+type jsonPattern struct {
+	Pattern string `json:"pattern"`
+	Count   int64  `json:"count"`
+}
+
+// jsonWordLengths is the -word-length-stats summary; the histogram maps
+// each average word length, rounded down, to the number of lines.
+type jsonWordLengths struct {
+	Lines     int64           `json:"lines"`
+	Mean      float64         `json:"mean"`
+	StdDev    float64         `json:"stddev"`
+	Histogram map[int64]int64 `json:"histogram"`
+}
+
+// jsonLineStats is the -line-stats summary of line lengths.
+type jsonLineStats struct {
+	Lines  int64   `json:"lines"`
+	Mean   float64 `json:"mean"`
+	Median float64 `json:"median"`
+	StdDev float64 `json:"stddev"`
+}
+
+// jsonDocument is the top-level object printed in -json mode.
+type jsonDocument struct {
+	Files []jsonCounts `json:"files"`
+	Total jsonCounts   `json:"total"`
+	OK    *bool        `json:"ok,omitempty"` // With -json-errors: did every input count?
+
+	Average map[string]float64 `json:"average,omitempty"` // With -averages
+}
+
+// toJSONCounts selects the fields of counts that should appear in the output.
+// With JSONAllFields every key is present, so consumers see a stable schema.
+func toJSONCounts(counts Counts, flags Flags, filename string) jsonCounts {
+	jc := jsonCounts{Filename: filename}
+	all := flags.JSONAllFields
+	if all || flags.ShowLines {
+		jc.Lines = &counts.Lines
+	}
+	if all || flags.ShowWords {
+		jc.Words = &counts.Words
+	}
+	if flags.ShowGraphemes {
+		jc.Graphemes = &counts.Graphemes
+	}
+	if all || flags.ShowChars {
+		jc.Chars = &counts.Chars
+	}
+	if all || flags.ShowBytes {
+		jc.Bytes = &counts.Bytes
+	}
+	if flags.ShowTrulyEmpty {
+		jc.TrulyEmpty = &counts.TrulyEmpty
+	}
+	if flags.ShowWhitespaceOnly {
+		jc.WhitespaceOnly = &counts.WhitespaceOnly
+	}
+	if flags.ShowPrintable {
+		jc.Printable = &counts.Printable
+	}
+	if flags.ShowControl {
+		jc.Control = &counts.Control
+	}
+	if flags.ShowWhitespaceBytes {
+		jc.WhitespaceBytes = &counts.WhitespaceBytes
+	}
+	if flags.ShowContentBytes {
+		jc.ContentBytes = &counts.ContentBytes
+	}
+	if flags.ShowEmoji {
+		jc.Emoji = &counts.Emoji
+	}
+	if flags.CountSubstr != "" {
+		jc.Substr = &counts.Substr
+	}
+	if flags.AdjacentDupes {
+		jc.Repeats, jc.LongestRun = &counts.Repeats, &counts.LongestRun
+	}
+	if flags.EdgeBlanks {
+		jc.LeadingBlanks, jc.TrailingBlanks = &counts.LeadingBlanks, &counts.TrailingBlanks
+	}
+	if flags.MatchesPerLine != "" {
+		jc.Matches = &counts.Matches
+	}
+	if flags.CountColumn > 0 {
+		jc.ColumnValues = &counts.ColumnValues
+		if flags.Unique {
+			distinct := int64(len(counts.ColumnDistinct))
+			jc.ColumnDistinct = &distinct
+		}
+	}
+	if flags.ShowMaxLine {
+		jc.MaxLine = &counts.MaxLine
+	}
+	if flags.ShowMaxLineBytes {
+		jc.MaxLineBytes = &counts.MaxLineBytes
+	}
+	if flags.OverLength > 0 {
+		jc.OverLength = &counts.OverLength
+		if flags.ListOverLength {
+			jc.OverLengthLines = counts.OverLengthLines
+		}
+	}
+	jc.ExitStatus = counts.ExitStatus
+	jc.BOM = counts.BOM
+	if flags.ShowLongest > 0 {
+		jc.LongestLines = counts.Longest
+	}
+	if flags.ShowUniqueWords {
+		unique := int64(len(counts.Freq))
+		jc.UniqueWords = &unique
+	}
+	if flags.DistinctChars {
+		distinct := int64(len(counts.Runes))
+		jc.DistinctChars = &distinct
+		if flags.ListChars {
+			list := string(sortedRunes(counts.Runes))
+			jc.CharList = &list
+		}
+	}
+	if flags.Stopwords != "" {
+		jc.FilteredWords = &counts.FilteredWords
+	}
+	if flags.MaxWordCap > 0 {
+		jc.CappedWords = &counts.CappedWords
+	}
+	if flags.Sample > 1 {
+		jc.SkippedBytes = &counts.SkippedBytes
+	}
+	if counts.Baseline != nil {
+		jc.Baseline = toJSONBaseline(counts.Baseline)
+	}
+	if flags.EOLReport {
+		jc.EOL = &jsonEOL{LF: counts.EOLLF, CRLF: counts.EOLCRLF, CR: counts.EOLCR}
+	}
+	if flags.WordLengthStats {
+		jc.WordLengths = &jsonWordLengths{Histogram: map[int64]int64{}}
+		if w := counts.WordLengths; w != nil {
+			jc.WordLengths = &jsonWordLengths{Lines: w.N, Mean: w.Mean, StdDev: w.StdDev(), Histogram: w.Hist}
+		}
+	}
+	if flags.LineStats {
+		jc.LineStats = &jsonLineStats{}
+		if s := counts.LineStats; s != nil {
+			jc.LineStats = &jsonLineStats{Lines: s.N, Mean: s.Mean, Median: s.Median(), StdDev: s.StdDev()}
+		}
+	}
+	if flags.IndentStats {
+		jc.Indents = counts.Indents
+	}
+	if flags.Script {
+		jc.Scripts = counts.Scripts
+	}
+	if flags.Encoding == encodingUTF16LE || flags.Encoding == encodingUTF16BE || flags.Encoding == encodingAuto {
+		jc.LoneSurrogates = &counts.LoneSurrogates
+	}
+	if r := counts.UTF8; r != nil {
+		jc.UTF8 = make(map[string]jsonUTF8Error, len(r.Errors))
+		for kind, e := range r.Errors {
+			je := jsonUTF8Error{Count: e.Count}
+			if e.First >= 0 {
+				first := e.First
+				je.FirstOffset = &first
+			}
+			jc.UTF8[utf8ErrorNames[kind]] = je
+		}
+	}
+	if flags.Fields != "" {
+		jc.Fields = &jsonFields{Consistent: true}
+		if s := counts.Fields; s != nil {
+			jc.Fields = &jsonFields{Lines: s.Lines, Min: s.Min, Max: s.Max, Consistent: s.Consistent()}
+		}
+	}
+	if flags.Readability {
+		r := counts.Readability
+		if r == nil {
+			r = &Readability{}
+		}
+		jc.Readability = &jsonReadability{
+			Sentences:             r.Sentences,
+			Paragraphs:            r.Paragraphs,
+			SentencesPerParagraph: r.SentencesPerParagraph(),
+			WordsPerSentence:      r.WordsPerSentence(),
+			Flesch:                r.Flesch(),
+		}
+	}
+	if flags.SentenceTypes {
+		r := counts.Readability
+		if r == nil {
+			r = &Readability{}
+		}
+		jc.SentenceTypes = &jsonSentenceTypes{
+			Periods:      r.Periods,
+			Exclamations: r.Exclamations,
+			Questions:    r.Questions,
+			Unterminated: r.Unterminated,
+		}
+	}
+	if flags.Top > 0 {
+		jc.TopWords = topWords(counts.Freq, flags.Top, flags.MinFrequency)
+	}
+	if flags.patterns != nil {
+		jc.Patterns = make([]jsonPattern, len(flags.patterns.patterns))
+		for i, pattern := range flags.patterns.patterns {
+			jc.Patterns[i].Pattern = pattern
+			if i < len(counts.Patterns) {
+				jc.Patterns[i].Count = counts.Patterns[i]
+			}
+		}
+		hits := patternHits(counts.Patterns)
+		jc.PatternHits = &hits
+	}
+	if counts.ApproxTop != nil {
+		jc.ApproxTopWords = counts.ApproxTop.Top()
+	}
+	if flags.Decompress {
+		jc.Compressed = &counts.Compressed
+		if ratio, ok := compressionRatio(counts); ok {
+			jc.Ratio = &ratio
+		}
+	}
+	if flags.BytesPerLine {
+		if perLine, ok := bytesPerLine(counts); ok {
+			jc.BytesPerLine = &perLine
+		}
+	}
+	if flags.Preview && counts.Preview != nil {
+		jc.FirstLine = &counts.Preview.First
+		jc.LastLine = &counts.Preview.Last
+	}
+	for _, r := range counts.Custom {
+		if jc.Custom == nil {
+			jc.Custom = make(map[string]interface{})
+		}
+		jc.Custom[r.Name] = r.Value
+	}
+	return jc
+}
+
+// formatJSON renders all per-file results and the total, which sums the
+// given number of inputs, as one JSON document.
+func formatJSON(results []fileResult, total Counts, inputs int, flags Flags) (string, error) {
+	doc := jsonDocument{
+		Files: make([]jsonCounts, 0, len(results)),
+		Total: toJSONCounts(total, flags, flags.TotalLabel),
+	}
+	ok := true
+	for _, r := range results {
+		if r.Err != nil {
+			doc.Files = append(doc.Files, jsonCounts{Filename: r.Filename, Error: r.Err.Error()})
+			ok = false
+			continue
+		}
+		doc.Files = append(doc.Files, toJSONCounts(r.Counts, flags, r.Filename))
+	}
+	if flags.JSONErrors {
+		doc.OK = &ok
+	}
+	if flags.Averages && inputs > 0 {
+		doc.Average = jsonAverages(total, inputs, flags)
+	}
+
+	var out []byte
+	var err error
+	if flags.JSONPretty {
+		out, err = json.MarshalIndent(doc, "", "  ")
+	} else {
+		out, err = json.Marshal(doc)
+	}
+	if err != nil {
+		return "", fmt.Errorf("error encoding JSON: %w", err)
+	}
+	return string(out), nil
+}
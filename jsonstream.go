@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// jsonArrayStream writes -json-stream-array output: a JSON array whose
+// elements are written one by one as the results come in, so a consumer
+// can start on them before the run is over, yet the whole output is valid
+// JSON once close has written the closing bracket.
+type jsonArrayStream struct {
+	w      io.Writer
+	pretty bool
+	n      int // Elements written so far
+	closed bool
+}
+
+// newJSONArrayStream writes the opening bracket of the array to w.
+func newJSONArrayStream(w io.Writer, pretty bool) *jsonArrayStream {
+	fmt.Fprint(w, "[")
+	return &jsonArrayStream{w: w, pretty: pretty}
+}
+
+// add writes v as the next element, each on a line of its own (or several,
+// indented, with -json-pretty).
+func (s *jsonArrayStream) add(v interface{}) error {
+	var data []byte
+	var err error
+	if s.pretty {
+		data, err = json.MarshalIndent(v, "  ", "  ")
+	} else {
+		data, err = json.Marshal(v)
+	}
+	if err != nil {
+		return fmt.Errorf("error encoding JSON: %w", err)
+	}
+
+	sep := "\n"
+	if s.n > 0 {
+		sep = ",\n"
+	}
+	if s.pretty {
+		sep += "  "
+	}
+	s.n++
+	_, err = fmt.Fprintf(s.w, "%s%s", sep, data)
+	return err
+}
+
+// close ends the array. It is safe to call more than once, and on a nil
+// stream, so that every way out of a run can call it.
+func (s *jsonArrayStream) close() {
+	if s == nil || s.closed {
+		return
+	}
+	s.closed = true
+	if s.n > 0 {
+		fmt.Fprint(s.w, "\n")
+	}
+	fmt.Fprintln(s.w, "]")
+}
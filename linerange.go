@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// parseLineRange parses a -line-range argument "N:M", the 1-based numbers
+// of the first and last line to count. Either may be left out, to start
+// at the first line or go on to the last; to is then 0.
+func parseLineRange(arg string) (from, to int64, err error) {
+	colon := strings.IndexByte(arg, ':')
+	if colon < 0 {
+		return 0, 0, fmt.Errorf("invalid line range %q: want N:M", arg)
+	}
+	from, to = 1, 0
+	if s := arg[:colon]; s != "" {
+		if from, err = strconv.ParseInt(s, 10, 64); err != nil || from < 1 {
+			return 0, 0, fmt.Errorf("invalid line range %q: bad first line", arg)
+		}
+	}
+	if s := arg[colon+1:]; s != "" {
+		if to, err = strconv.ParseInt(s, 10, 64); err != nil || to < from {
+			return 0, 0, fmt.Errorf("invalid line range %q: bad last line", arg)
+		}
+	}
+	return from, to, nil
+}
+
+// lineRangeFilter is a reader that passes on only the lines numbered from
+// to to (0 for the last line) of its source, terminators included. Lines
+// before the range are read only to find where it starts; once it is over
+// the source is not read any further, so a range at the start of a large
+// file is quick to count.
+type lineRangeFilter struct {
+	src      *bufio.Reader
+	from, to int64
+	line     int64  // Number of the next line
+	out      []byte // Text not yet returned to the caller
+	err      error
+}
+
+func newLineRangeFilter(src io.Reader, from, to int64) *lineRangeFilter {
+	return &lineRangeFilter{src: bufio.NewReaderSize(src, bufferSize), from: from, to: to, line: 1}
+}
+
+func (l *lineRangeFilter) Read(p []byte) (int, error) {
+	// Keep reading while lines are before the range, so that a 0, nil result
+	// never reaches the caller
+	for len(l.out) == 0 && l.err == nil {
+		l.readLine()
+	}
+
+	n := copy(p, l.out)
+	l.out = l.out[n:]
+	if len(l.out) > 0 {
+		return n, nil
+	}
+	l.out = l.out[:0]
+	return n, l.err
+}
+
+// readLine reads the next line from src, appending it to out if it is in
+// the range.
+func (l *lineRangeFilter) readLine() {
+	if l.to > 0 && l.line > l.to {
+		l.err = io.EOF
+		return
+	}
+	inRange := l.line >= l.from
+	for {
+		chunk, err := l.src.ReadSlice('\n')
+		if inRange {
+			l.out = append(l.out, chunk...)
+		}
+		if err == bufio.ErrBufferFull {
+			continue // A line longer than the buffer
+		}
+		if err != nil {
+			l.err = err
+		}
+		break
+	}
+	l.line++
+}
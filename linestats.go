@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// LineStats accumulates the distribution of line lengths, in characters
+// and excluding the line terminator, for -line-stats. Mean and variance
+// are kept with Welford's streaming algorithm; the median comes from a
+// histogram of lengths, which stays small as long as the number of distinct
+// lengths does, however many lines there are.
+type LineStats struct {
+	N    int64
+	Mean float64
+	M2   float64 // Sum of squared differences from the mean
+
+	hist map[int64]int64 // Line length -> number of lines
+}
+
+// newLineStats returns an empty accumulator.
+func newLineStats() *LineStats {
+	return &LineStats{hist: make(map[int64]int64)}
+}
+
+// Add records one line of the given length.
+func (s *LineStats) Add(length int64) {
+	s.N++
+	delta := float64(length) - s.Mean
+	s.Mean += delta / float64(s.N)
+	s.M2 += delta * (float64(length) - s.Mean)
+	s.hist[length]++
+}
+
+// Merge combines the statistics of other into s, as if all of other's
+// lines had been added to s.
+func (s *LineStats) Merge(other *LineStats) {
+	if other == nil || other.N == 0 {
+		return
+	}
+	n := s.N + other.N
+	delta := other.Mean - s.Mean
+	s.M2 += other.M2 + delta*delta*float64(s.N)*float64(other.N)/float64(n)
+	s.Mean += delta * float64(other.N) / float64(n)
+	s.N = n
+	for length, lines := range other.hist {
+		s.hist[length] += lines
+	}
+}
+
+// StdDev returns the population standard deviation of the line lengths,
+// which is 0 for a single line.
+func (s *LineStats) StdDev() float64 {
+	if s.N == 0 {
+		return 0
+	}
+	return math.Sqrt(s.M2 / float64(s.N))
+}
+
+// Median returns the median line length; with an even number of lines it
+// is the mean of the two middle lengths.
+func (s *LineStats) Median() float64 {
+	if s.N == 0 {
+		return 0
+	}
+	lengths := make([]int64, 0, len(s.hist))
+	for length := range s.hist {
+		lengths = append(lengths, length)
+	}
+	sort.Slice(lengths, func(i, j int) bool { return lengths[i] < lengths[j] })
+
+	// nth returns the length of the line at 0-based position i in sorted order
+	nth := func(i int64) int64 {
+		for _, length := range lengths {
+			if i < s.hist[length] {
+				return length
+			}
+			i -= s.hist[length]
+		}
+		return lengths[len(lengths)-1]
+	}
+	if s.N%2 == 1 {
+		return float64(nth(s.N / 2))
+	}
+	return float64(nth(s.N/2-1)+nth(s.N/2)) / 2
+}
+
+// formatLineStats describes the line length distribution for -line-stats.
+func formatLineStats(s *LineStats) string {
+	if s == nil || s.N == 0 {
+		return "    line length: no lines"
+	}
+	return fmt.Sprintf("    line length: mean=%.2f median=%.1f stddev=%.2f", s.Mean, s.Median(), s.StdDev())
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"container/heap"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// LongLine is one of the lines listed by -show-longest: its number and its
+// display width, measured like -L.
+type LongLine struct {
+	Line  int64 `json:"line"`
+	Width int64 `json:"width"`
+}
+
+// longestLines keeps the n widest lines seen so far in a min-heap, so the
+// narrowest of them is the one to give way when a wider line comes along.
+// Of equally wide lines the earlier ones are kept.
+type longestLines struct {
+	n     int
+	lines longLineHeap
+}
+
+func newLongestLines(n int) *longestLines {
+	return &longestLines{n: n}
+}
+
+// add considers the line numbered line, of the given width.
+func (l *longestLines) add(line, width int64) {
+	entry := LongLine{Line: line, Width: width}
+	if len(l.lines) < l.n {
+		heap.Push(&l.lines, entry)
+	} else if l.lines.less(l.lines[0], entry) {
+		l.lines[0] = entry
+		heap.Fix(&l.lines, 0)
+	}
+}
+
+// sorted returns the lines kept, widest first and in file order among
+// lines of the same width.
+func (l *longestLines) sorted() []LongLine {
+	lines := append([]LongLine(nil), l.lines...)
+	sort.Slice(lines, func(i, j int) bool {
+		return l.lines.less(lines[j], lines[i])
+	})
+	return lines
+}
+
+// longLineHeap orders lines narrowest first, and among lines of the same
+// width, the later line first.
+type longLineHeap []LongLine
+
+func (h longLineHeap) less(a, b LongLine) bool {
+	if a.Width != b.Width {
+		return a.Width < b.Width
+	}
+	return a.Line > b.Line
+}
+
+func (h longLineHeap) Len() int            { return len(h) }
+func (h longLineHeap) Less(i, j int) bool  { return h.less(h[i], h[j]) }
+func (h longLineHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *longLineHeap) Push(x interface{}) { *h = append(*h, x.(LongLine)) }
+func (h *longLineHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// formatLongest lists the lines found by -show-longest.
+func formatLongest(lines []LongLine) string {
+	parts := make([]string, len(lines))
+	for i, l := range lines {
+		parts[i] = fmt.Sprintf("line %d (%d)", l.Line, l.Width)
+	}
+	return "    longest: " + strings.Join(parts, ", ")
+}
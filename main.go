@@ -2,26 +2,717 @@ package main
 
 import (
 	"bufio"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"os"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 	"unicode"
+
+	"gowc/counters"
 )
 
-// Counts holds the line, word, and byte counts.
+// Counts holds the line, word, character, and byte counts.
 type Counts struct {
 	Lines int64
 	Words int64
+	Chars int64
 	Bytes int64
+
+	TrulyEmpty     int64 // Lines with no bytes at all before the terminator
+	WhitespaceOnly int64 // Non-empty lines made up entirely of whitespace
+
+	Printable int64 // Characters satisfying unicode.IsPrint
+	Control   int64 // Control characters (unicode.IsControl), including '\n' and '\t'
+	Emoji     int64 // Emoji and other symbols, a joined sequence counting once (see onEmojiRune)
+	Graphemes int64 // User-perceived characters, extended grapheme clusters (see graphemeCounter)
+	Substr    int64 // Occurrences of the -count-substr string
+
+	// Patterns counts the occurrences of each -patterns pattern, in the
+	// order of the file; Merge adds them up pattern by pattern.
+	Patterns []int64
+	Matches  int64 // Matches of the -matches-per-line regexp, several per line included
+
+	// WhitespaceBytes and ContentBytes split the bytes into whitespace and
+	// everything else, each byte tested like the word count does.
+	WhitespaceBytes int64
+	ContentBytes    int64
+
+	// ColumnValues counts the non-empty values in the -count-column field;
+	// ColumnDistinct holds the distinct ones with -unique (nil otherwise).
+	ColumnValues   int64
+	ColumnDistinct map[string]bool
+
+	// MaxLine is the width of the widest line (-L) and OverLength the
+	// number of lines wider than -over-length; OverLengthLines lists their
+	// line numbers with -list-over-length, and Longest the widest lines with
+	// -show-longest. Merge keeps the largest MaxLine and does not carry over
+	// OverLengthLines or Longest, which are per input.
+	MaxLine         int64
+	OverLength      int64
+	OverLengthLines []int64
+	Longest         []LongLine
+
+	// ExitStatus is the exit status of the -exec command whose output was
+	// counted (nil for other inputs). Merge does not carry it over.
+	ExitStatus *int
+
+	// Repeats counts the lines identical to the line before them and
+	// LongestRun is the most identical lines in a row, for -adjacent-dupes.
+	// Merge adds up the repeats and keeps the longest run; a run does not
+	// continue from one input into the next.
+	Repeats    int64
+	LongestRun int64
+
+	// LeadingBlanks and TrailingBlanks count the blank lines at the start
+	// and the end of the input, for -edge-blanks; Merge adds them up.
+	LeadingBlanks  int64
+	TrailingBlanks int64
+
+	// MaxLineBytes is the length in bytes of the longest line, for
+	// -widest-line-bytes; like MaxLine, Merge keeps the largest.
+	MaxLineBytes int64
+
+	// Line terminators by type, tallied with -eol-report
+	EOLLF   int64 // Bare '\n'
+	EOLCRLF int64 // "\r\n"
+	EOLCR   int64 // Bare '\r'
+
+	// Baseline is the change since the -baseline run, set just before the
+	// result is printed; Merge leaves it out, as the total is compared on
+	// its own.
+	Baseline *BaselineDelta
+
+	// SkippedBytes counts the bytes -sample left out; the lines, words and
+	// characters are then estimates, and Merge adds it up.
+	SkippedBytes int64
+
+	// Compressed is the size of the input as read with -decompress, before
+	// decompression; for input that wasn't compressed it equals Bytes.
+	Compressed int64
+
+	// LineStats describes the line lengths with -line-stats.
+	LineStats *LineStats
+
+	// WordLengths describes the average word length of the lines with
+	// -word-length-stats.
+	WordLengths *WordLengths
+
+	// Indents maps each indentation width to the number of non-blank lines
+	// indented that far, with -indent-stats.
+	Indents map[int64]int64
+
+	// FilteredWords counts the words that are not stopwords with -stopwords
+	// (all words without it, when word content is captured at all).
+	FilteredWords int64
+
+	// CappedWords counts the captured words longer than -max-word-cap,
+	// which were truncated before being tallied.
+	CappedWords int64
+
+	// Freq maps each case-folded word to its number of occurrences, for
+	// -unique-words and -top. It is nil unless one of them was requested.
+	Freq map[string]int64
+
+	// Runes is the set of distinct characters with -distinct-chars, and
+	// nil otherwise.
+	Runes map[rune]bool
+
+	// Scripts maps Unicode script names to the number of characters in
+	// each with -script.
+	Scripts map[string]int64
+
+	// ApproxTop estimates the most frequent words with -approx-top.
+	ApproxTop *TopSketch
+
+	// LoneSurrogates counts the unpaired surrogates in UTF-16 input, each
+	// of which decodes to U+FFFD.
+	LoneSurrogates int64
+
+	// UTF8 breaks down the malformed UTF-8 found with -utf8-report.
+	UTF8 *UTF8Report
+
+	// Fields holds the number of fields per line with -fields.
+	Fields *FieldStats
+
+	// Readability holds the sentence, paragraph and syllable counts with
+	// -readability.
+	Readability *Readability
+
+	// Preview holds the first and last line with -preview. It describes a
+	// single input and is therefore not carried over by Merge.
+	Preview *LinePreview
+
+	// Custom holds the results of registered counters (see package
+	// counters). They are opaque, so they cannot be added up by Merge either.
+	Custom []counters.Result
+
+	// Digest is the SHA-256 of the counted bytes with -dedup, as hex. Like
+	// Preview it stays with the input it describes.
+	Digest string
+
+	// BOM is the byte order mark the input starts with for -report-bom:
+	// "utf-8", "utf-16le", "utf-16be" or "none". It too is per input.
+	BOM string
+}
+
+// Merge adds the counts in other to c, e.g. to accumulate a total. It
+// fails rather than wrap around if a sum does not fit into an int64; c is
+// left partially updated in that case and should not be used any more.
+func (c *Counts) Merge(other Counts) error {
+	sums := []struct {
+		total *int64
+		value int64
+	}{
+		{&c.Lines, other.Lines},
+		{&c.Words, other.Words},
+		{&c.Chars, other.Chars},
+		{&c.Bytes, other.Bytes},
+		{&c.TrulyEmpty, other.TrulyEmpty},
+		{&c.WhitespaceOnly, other.WhitespaceOnly},
+		{&c.Printable, other.Printable},
+		{&c.Control, other.Control},
+		{&c.Emoji, other.Emoji},
+		{&c.Graphemes, other.Graphemes},
+		{&c.Matches, other.Matches},
+		{&c.Repeats, other.Repeats},
+		{&c.LeadingBlanks, other.LeadingBlanks},
+		{&c.TrailingBlanks, other.TrailingBlanks},
+		{&c.WhitespaceBytes, other.WhitespaceBytes},
+		{&c.ContentBytes, other.ContentBytes},
+		{&c.Substr, other.Substr},
+		{&c.ColumnValues, other.ColumnValues},
+		{&c.OverLength, other.OverLength},
+		{&c.Compressed, other.Compressed},
+		{&c.LoneSurrogates, other.LoneSurrogates},
+		{&c.EOLLF, other.EOLLF},
+		{&c.EOLCRLF, other.EOLCRLF},
+		{&c.EOLCR, other.EOLCR},
+		{&c.FilteredWords, other.FilteredWords},
+		{&c.CappedWords, other.CappedWords},
+		{&c.SkippedBytes, other.SkippedBytes},
+	}
+	for _, s := range sums {
+		if !addInt64(s.total, s.value) {
+			return errTotalOverflow
+		}
+	}
+	if other.MaxLine > c.MaxLine {
+		c.MaxLine = other.MaxLine
+	}
+	if other.MaxLineBytes > c.MaxLineBytes {
+		c.MaxLineBytes = other.MaxLineBytes
+	}
+	if other.LongestRun > c.LongestRun {
+		c.LongestRun = other.LongestRun
+	}
+
+	if other.Patterns != nil {
+		if c.Patterns == nil {
+			c.Patterns = make([]int64, len(other.Patterns))
+		}
+		for i := range other.Patterns {
+			if !addInt64(&c.Patterns[i], other.Patterns[i]) {
+				return errTotalOverflow
+			}
+		}
+	}
+
+	if other.LineStats != nil {
+		if c.LineStats == nil {
+			c.LineStats = newLineStats()
+		}
+		c.LineStats.Merge(other.LineStats)
+	}
+	if other.WordLengths != nil {
+		if c.WordLengths == nil {
+			c.WordLengths = newWordLengths()
+		}
+		c.WordLengths.Merge(other.WordLengths)
+	}
+	if other.ColumnDistinct != nil {
+		if c.ColumnDistinct == nil {
+			c.ColumnDistinct = make(map[string]bool)
+		}
+		for value := range other.ColumnDistinct {
+			c.ColumnDistinct[value] = true
+		}
+	}
+	if other.Scripts != nil {
+		if c.Scripts == nil {
+			c.Scripts = make(map[string]int64)
+		}
+		for name, n := range other.Scripts {
+			c.Scripts[name] += n
+		}
+	}
+	if other.ApproxTop != nil {
+		if c.ApproxTop == nil {
+			c.ApproxTop = newTopSketch(other.ApproxTop.k)
+		}
+		c.ApproxTop.Merge(other.ApproxTop)
+	}
+	if other.UTF8 != nil {
+		if c.UTF8 == nil {
+			c.UTF8 = newUTF8Report()
+		}
+		c.UTF8.Merge(other.UTF8)
+	}
+	if other.Fields != nil {
+		if c.Fields == nil {
+			c.Fields = &FieldStats{}
+		}
+		c.Fields.Merge(other.Fields)
+	}
+	if other.Readability != nil {
+		if c.Readability == nil {
+			c.Readability = &Readability{}
+		}
+		c.Readability.Merge(other.Readability)
+	}
+	if other.Indents != nil {
+		if c.Indents == nil {
+			c.Indents = make(map[int64]int64)
+		}
+		for width, lines := range other.Indents {
+			c.Indents[width] += lines
+		}
+	}
+	if other.Freq != nil {
+		if c.Freq == nil {
+			c.Freq = make(map[string]int64)
+		}
+		for word, n := range other.Freq {
+			c.Freq[word] += n
+		}
+	}
+	if other.Runes != nil {
+		if c.Runes == nil {
+			c.Runes = make(map[rune]bool)
+		}
+		for r := range other.Runes {
+			c.Runes[r] = true
+		}
+	}
+	return nil
+}
+
+// errTotalOverflow is returned by Merge when a total gets too large.
+var errTotalOverflow = errors.New("total too large: int64 overflow")
+
+// addInt64 adds value to *total unless that overflows, reporting whether it
+// did not.
+func addInt64(total *int64, value int64) bool {
+	sum := *total + value
+	if (value > 0 && sum < *total) || (value < 0 && sum > *total) {
+		return false
+	}
+	*total = sum
+	return true
 }
 
-// Flags holds the boolean flags indicating which counts to display.
+// Flags holds the boolean flags indicating which counts to display,
+// along with options that change how counting is performed.
 type Flags struct {
 	ShowLines bool
 	ShowWords bool
+	ShowChars bool
 	ShowBytes bool
+
+	// ShowGraphemes counts grapheme clusters; Widths selects them along
+	// with characters and bytes, to compare the three at a glance.
+	ShowGraphemes bool
+	Widths        bool
+
+	ShowTrulyEmpty      bool
+	ShowWhitespaceOnly  bool
+	ShowPrintable       bool
+	ShowControl         bool
+	ShowEmoji           bool
+	ShowMaxLine         bool // Width of the widest line, like wc -L
+	ShowMaxLineBytes    bool // Length in bytes of the longest line
+	BytesPerLine        bool // Average bytes per line, a derived column
+	ShowUniqueWords     bool // Distinct case-folded words
+	DistinctChars       bool // Distinct characters, the size of the alphabet
+	ShowWhitespaceBytes bool // Bytes that are whitespace
+	ShowContentBytes    bool // Bytes that are not
+	ListChars           bool // With DistinctChars, list them below each result
+
+	// Script reports how many characters belong to each Unicode script.
+	Script bool
+
+	// UTF8Report classifies the malformed UTF-8 in each input.
+	UTF8Report bool
+
+	// JSON switches the output to a single JSON document. With JSONAllFields
+	// every count key is always present, whichever columns were selected.
+	JSON          bool
+	JSONAllFields bool
+	JSONPretty    bool // Indent the JSON document for human readers
+	JSONErrors    bool // Report per-input errors inside the document
+
+	// JSONStreamArray writes the results as a JSON array instead, each
+	// element as soon as it is complete and the total last. The other
+	// JSON options apply to it too.
+	JSONStreamArray bool
+
+	// AnyEOL treats '\r', '\n' and "\r\n" each as a single line terminator.
+	// EOLReport prints how many of each kind of terminator were found.
+	AnyEOL    bool
+	EOLReport bool
+
+	// Recursive walks directory arguments and counts every regular file in them.
+	// RelativeTo, if set, makes printed filenames relative to that directory.
+	Recursive  bool
+	RelativeTo string
+
+	// MaxFiles stops after this many files have been counted (0 means no
+	// limit); the total then only covers those.
+	MaxFiles int
+
+	// NewerThan and OlderThan only count the files found by -r that were
+	// last modified less (more) than this long ago; 0 means no limit.
+	NewerThan time.Duration
+	OlderThan time.Duration
+
+	// DirSummary prints one line per argument, summing up the files -r
+	// finds below it, instead of one line per file.
+	DirSummary bool
+
+	// SafeNames escapes control characters in printed filenames (see
+	// escapeName), so that a newline in one cannot split its line.
+	SafeNames bool
+
+	// TrimPrefix strips this prefix from printed filenames, and Basename
+	// prints only their last element. Both are for display only.
+	TrimPrefix string
+	Basename   bool
+
+	// Merge counts all inputs as one concatenated stream, reported under MergeLabel.
+	Merge      bool
+	MergeLabel string
+
+	// TotalLabel is printed in place of a filename on the total line.
+	TotalLabel string
+
+	// ColumnOrder reorders the line, word, character and byte columns, as
+	// letters like the wc options (see parseColumnOrder).
+	ColumnOrder string
+
+	// WordChars lists the non-alphanumeric characters that are part of a
+	// word. When set, words are runs of letters, digits and these characters.
+	WordChars string
+
+	// UnicodeSpace splits words on the Unicode white space characters of
+	// the decoded text, such as U+00A0 and U+3000, rather than testing each
+	// byte on its own, which misses most of those and splits some letters.
+	UnicodeSpace bool
+
+	// Segment splits words by the rules and the dictionary for this
+	// language, for text without spaces between its words (see segmenter).
+	// SegmentDict names a file of words to add to the dictionary, and
+	// segmentDict is the dictionary extended with them.
+	Segment     string
+	SegmentDict string
+	segmentDict *segmentDict
+
+	// Stopwords names a file of words excluded from the filtered word count,
+	// -unique-words and -top; stopwords is the case-folded set loaded from it.
+	// Top lists this many of the most frequent words below each result,
+	// leaving out those occurring fewer than MinFrequency times.
+	Stopwords    string
+	stopwords    map[string]bool
+	Top          int
+	MinFrequency int64
+
+	// Patterns names a file of byte strings whose occurrences are each
+	// counted, in one pass; patterns is the automaton built from them.
+	Patterns string
+	patterns *patternSet
+
+	// Baseline names the -json output of an earlier run to print the change
+	// of every result since then; baseline is the loaded document.
+	Baseline string
+	baseline *baseline
+
+	// ApproxTop lists this many of the most frequent words too, but as
+	// estimated in bounded memory (see TopSketch).
+	ApproxTop int
+
+	// CountSubstr, if set, counts the occurrences of this byte string;
+	// Overlapping counts occurrences that overlap each other ("aa" occurs
+	// twice in "aaa") rather than only disjoint ones.
+	CountSubstr string
+	Overlapping bool
+
+	// CountColumn counts the non-empty values of this field (from 1; 0
+	// disables it) on each line, splitting lines on FieldSep. Unique also
+	// counts the distinct values.
+	CountColumn int
+	FieldSep    string
+	Unique      bool
+
+	// MinWordLen ignores words shorter than this many characters in every
+	// word count, -unique-words and -top included.
+	MinWordLen int
+
+	// MaxWordCap truncates the words captured for -stopwords, -unique-words
+	// and -top to this many characters (0 for no limit), so that a huge
+	// run of non-space bytes cannot use up the memory. The word count
+	// itself is unaffected.
+	MaxWordCap int
+
+	// NoCollapseDelims counts every whitespace character as a delimiter
+	// of its own, so that two in a row enclose an empty word.
+	NoCollapseDelims bool
+
+	// StripTags removes HTML markup before counting (see tagStripper).
+	StripTags bool
+
+	// NFC and NFD normalize the text to that Unicode normalization form
+	// before counting (see normReader).
+	NFC bool
+	NFD bool
+
+	// Offset and Length restrict counting to a byte range of each input.
+	// A negative Length means "until the end of the input".
+	Offset int64
+	Length int64
+
+	// BytesTotal prints nothing but the total byte count of all inputs.
+	BytesTotal bool
+
+	// Verbose describes the counting modes in effect on stderr.
+	Verbose bool
+
+	// DirectRead reads the inputs without the bufio.Reader layer.
+	DirectRead bool
+
+	// Sample only counts every Sample'th buffer of an input and scales the
+	// line, word and character counts up from it (see sampleReader).
+	Sample int
+
+	// Compare counts exactly two files and prints the change between them.
+	Compare bool
+
+	// WatchDir, if set, keeps counting the files created or modified in
+	// that directory until interrupted, checking every WatchInterval.
+	WatchDir      string
+	WatchInterval time.Duration
+
+	// HideEmpty leaves out the results whose enabled counts are all zero.
+	HideEmpty bool
+
+	// Headers prints a row naming the columns above the first result.
+	Headers bool
+
+	// Prose prints each result as an English sentence instead of columns,
+	// KV as key=value pairs. Thousands groups the digits of printed counts
+	// with commas.
+	Prose     bool
+	KV        bool
+	Thousands bool
+
+	// Prometheus prints all results as Prometheus metrics at the end.
+	Prometheus bool
+
+	// LineStats reports the mean, median and standard deviation of the
+	// line lengths below each result.
+	LineStats bool
+
+	// WordLengthStats reports the distribution of the lines' average word
+	// lengths below each result.
+	WordLengthStats bool
+
+	// IndentStats reports a histogram of the lines' leading whitespace
+	// widths, with tabs expanded to multiples of TabWidth.
+	IndentStats bool
+	TabWidth    int
+
+	// Readability reports sentence and paragraph lengths and a Flesch
+	// reading-ease score. SentenceTypes breaks the sentences it finds down
+	// by their final punctuation.
+	Readability   bool
+	SentenceTypes bool
+
+	// Fields reports how many fields, separated by this string, the lines
+	// have (see FieldStats); empty disables it.
+	Fields string
+
+	// OverLength counts the lines wider than this many columns (0 disables
+	// it); ListOverLength also lists their line numbers.
+	OverLength     int64
+	ListOverLength bool
+
+	// ShowLongest lists this many of the widest lines of each input.
+	ShowLongest int
+
+	// Preview prints the first and last line of each input, cut down to
+	// PreviewWidth characters.
+	Preview      bool
+	PreviewWidth int
+
+	// SortByName prints the results sorted by filename rather than in the
+	// order they were counted.
+	SortByName bool
+
+	// Normalize adds each count as a percentage of the grand total.
+	Normalize bool
+
+	// Averages prints the mean of each count per input after the total.
+	Averages bool
+
+	// RunningTotal prints the sum so far after each result.
+	RunningTotal bool
+
+	// NoTotalOnError leaves out the total (and the averages) when any input
+	// could not be counted.
+	NoTotalOnError bool
+
+	// Annotate, if set, prepends a line "<Annotate> lines=... words=..." to
+	// every counted regular file. DryRun only prints what would be written.
+	Annotate string
+	DryRun   bool
+
+	// Decompress transparently decompresses gzip input and adds columns for
+	// the compressed size and the compression ratio.
+	Decompress bool
+
+	// LineRange selects the lines to count by number, as "N:M".
+	LineRange string
+
+	// Match selects the lines matching a regexp, which are then counted on
+	// their own; match is the compiled form. OnlyMatching counts only the
+	// matched parts of those lines, like grep -o.
+	Match        string
+	match        *regexp.Regexp
+	OnlyMatching bool
+
+	// MatchesPerLine counts all matches of a regexp on every line, rather
+	// than the lines matching it; matches is the compiled form.
+	MatchesPerLine string
+	matches        *regexp.Regexp
+
+	// SplitOn splits standard input into segments at every line that
+	// holds just this sentinel, and counts each segment on its own.
+	SplitOn string
+
+	// ReportEmptyFiles tallies the named files without a single byte, on
+	// stderr at the end of the run; ListEmpty also lists them.
+	ReportEmptyFiles bool
+	ListEmpty        bool
+
+	// RecordLength counts every this many bytes as a line, for fixed-width
+	// records without delimiters; PartialRecord counts a shorter final one.
+	RecordLength  int64
+	PartialRecord bool
+
+	// AdjacentDupes reports the lines repeating the previous line and the
+	// longest run of identical lines.
+	AdjacentDupes bool
+
+	// EdgeBlanks reports the blank lines at the start and end of each input.
+	EdgeBlanks bool
+
+	// Base64Decode decodes base64 input before counting it, in the URL-safe
+	// alphabet with Base64URL.
+	Base64Decode bool
+	Base64URL    bool
+
+	// SkipBinary skips inputs whose start is less than TextThreshold text
+	// (see textRatio).
+	SkipBinary    bool
+	TextThreshold float64
+
+	// ReportBOM reports the byte order mark each input starts with.
+	ReportBOM bool
+
+	// PipeThrough is a shell command each input is piped through; its
+	// output is counted instead of the input itself.
+	PipeThrough string
+
+	// Encoding is the character encoding of the inputs (see decodeInput);
+	// "auto" detects it per file. DetectEncoding only reports the guess.
+	Encoding       string
+	DetectEncoding bool
+
+	// HasNUL only reports whether each input contains a NUL byte, and where.
+	// ASCIIOnly likewise reports where the first non-ASCII byte is.
+	HasNUL    bool
+	ASCIIOnly bool
+
+	// FDs lists inherited file descriptors to count in addition to the
+	// named files.
+	FDs fdList
+
+	// Clipboard also counts the text on the system clipboard.
+	Clipboard bool
+
+	// Exec also counts the standard output of this shell command.
+	Exec string
+
+	// StateFile enables incremental counting: only bytes appended since the
+	// previous run are counted. state is loaded from it at startup.
+	StateFile string
+	state     *incrementalState
+
+	// Jobs is the number of files counted concurrently. Progress shows a
+	// status line on stderr while they are counted.
+	Jobs     int
+	Progress bool
+
+	// VerifyAgainstWC compares the counts with those of the system wc
+	// instead of printing them; a hidden development aid.
+	VerifyAgainstWC bool
+
+	// RateLimit caps reading at this many bytes per second (0 means unlimited).
+	RateLimit int64
+
+	// Tee names a file that receives a copy of everything counted; tee is
+	// the open target.
+	Tee string
+	tee *teeWriter
+
+	// Checkpoint names a file recording the progress of the run, from
+	// which Resume continues an interrupted one (see checkpointState).
+	Checkpoint string
+	Resume     bool
+
+	// Dedup skips inputs whose content is identical to one counted before,
+	// recognizing them by the Digest of their counts.
+	Dedup bool
+
+	// Server answers commands read from stdin instead of counting the
+	// arguments (see serve).
+	Server bool
+}
+
+// noneSelected reports whether no count column was requested explicitly.
+func (f Flags) noneSelected() bool {
+	return !f.ShowLines && !f.ShowWords && !f.ShowChars && !f.ShowBytes &&
+		!f.ShowTrulyEmpty && !f.ShowWhitespaceOnly && !f.ShowPrintable && !f.ShowControl &&
+		!f.ShowUniqueWords && !f.ShowEmoji && !f.ShowMaxLine && !f.ShowMaxLineBytes &&
+		!f.ShowGraphemes && !f.DistinctChars && !f.ShowWhitespaceBytes && !f.ShowContentBytes
+}
+
+// fdList collects the values of the repeatable -fd flag.
+type fdList []int
+
+func (l *fdList) String() string {
+	return fmt.Sprint(*l)
+}
+
+func (l *fdList) Set(value string) error {
+	fd, err := strconv.Atoi(value)
+	if err != nil || fd < 0 {
+		return fmt.Errorf("invalid file descriptor %q", value)
+	}
+	*l = append(*l, fd)
+	return nil
 }
 
 const (
@@ -30,190 +721,2270 @@ const (
 	bufferSize = 64 * 1024
 )
 
-// count performs the counting operation on the given reader.
-// It's optimized by reading in large chunks and processing the buffer.
-func count(reader io.Reader) (Counts, error) {
-	var counts Counts
-	// Use bufio.Reader with a specified large buffer size for performance.
-	br := bufio.NewReaderSize(reader, bufferSize)
-	buf := make([]byte, bufferSize) // Reusable buffer for Read calls
+// counter holds the state of the counting state machine. Input is fed to it
+// chunk by chunk, and all state needed to follow words and lines across
+// chunk boundaries lives here rather than in the read loop.
+type counter struct {
+	flags  Flags
+	counts Counts
 
-	inWord := false // State machine: are we currently inside a word?
+	inWord bool // State machine: are we currently inside a word?
+	prevCR bool // Was the previous byte a '\r'? Used by AnyEOL to fold "\r\n".
 
-	for {
-		// Read a chunk from the buffered reader into our local buffer.
-		// This minimizes the number of underlying system calls.
-		n, err := br.Read(buf)
+	// -eol-report needs to see the byte after a '\r' before it can tell a
+	// bare '\r' from "\r\n"; pendingCR remembers one across chunks.
+	pendingCR bool
+
+	// Characters are only counted when someone is going to look at them.
+	// The check is cheap, but it still costs an extra branch per byte.
+	// The same goes for splitting bytes into whitespace and content.
+	countChars bool
+	splitBytes bool
+
+	// fastLines is set when only lines need counting, which countLines
+	// does without the state machine (see canCountLinesFast).
+	fastLines bool
+
+	// Per-line state, only tracked when a per-line metric was requested.
+	trackLines   bool
+	lineLen      int64 // Bytes on the current line so far, excluding the terminator
+	lineBlank    bool  // Has the current line held nothing but whitespace so far?
+	lineChars    int64 // Characters on the current line so far, for -line-stats
+	indenting    bool  // Still in the leading whitespace of the line (-indent-stats)?
+	indent       int64 // Width of that leading whitespace so far
+	lineWidth    int64 // Display width of the current line so far, for -L and -over-length
+	lineBytes    int64 // Bytes on the current line so far, for -widest-line-bytes
+	measureWidth bool
+	preview      *linePreview
+	substr       *substrCounter
+	patterns     *patternCounter // -patterns
+	utf8         *utf8Checker
+	fields       *fieldCounter // -fields
+	column       *fieldCounter // -count-column
+
+	// Character-level metrics need the input decoded as UTF-8 (see
+	// feedRunes), which is much slower than the byte loop, so it only runs
+	// when decodeRunes is set. When isWordRune is set the byte loop leaves
+	// word counting to the decoding pass.
+	decodeRunes bool
+	isWordRune  func(r rune) bool
+	segmenter   *segmenter // With -segment, splits runs of word characters further
+	carry       []byte     // Bytes of a character split across chunks
+	scratch     []byte     // Reusable buffer for joining carry with the next chunk
+
+	// With -stopwords, -unique-words or -top the bytes of each word are
+	// collected in word and handed to endWord once the word is complete.
+	// With -min-word-len words are only counted there too, once their
+	// length in characters (wordLen) is known; trackWords is set if either
+	// needs endWord.
+	captureWords bool
+	trackWords   bool
+	word         []byte
+	wordLen      int
+
+	// -emoji state, to count a joined emoji sequence or a flag only once
+	inEmoji         bool // Was the last character part of an emoji?
+	emojiJoined     bool // Did a zero width joiner follow that emoji?
+	pendingRegional bool // Was it the first regional indicator of a flag?
+
+	// -no-collapse-delims state: the delimiters on the current line so
+	// far, and whether the line has any bytes at all
+	delims    int64
+	delimLine bool
+
+	graphemes   *graphemeCounter    // With -graphemes
+	readability *readabilityCounter // With -readability
+	longest     *longestLines       // With -show-longest
+	matches     *matchTally         // With -matches-per-line
+	dupes       *dupeTracker        // With -adjacent-dupes
+	wordLengths *wordLengthTracker  // With -word-length-stats
+	edges       *edgeBlanks         // With -edge-blanks
+
+	scriptCache map[rune]string // -script lookups done so far (see scriptOf)
+}
+
+// newCounter returns a counter ready to be fed the start of an input.
+func newCounter(flags Flags) *counter {
+	c := &counter{
+		flags:        flags,
+		countChars:   flags.ShowChars || flags.JSONAllFields,
+		measureWidth: flags.ShowMaxLine || flags.ShowMaxLineBytes || flags.OverLength > 0 || flags.ShowLongest > 0,
+		trackLines:   flags.ShowTrulyEmpty || flags.ShowWhitespaceOnly || flags.LineStats,
+		splitBytes:   flags.ShowWhitespaceBytes || flags.ShowContentBytes,
+		lineBlank:    true,
+		isWordRune:   wordRuneFunc(flags),
+
+		captureWords: flags.stopwords != nil || flags.ShowUniqueWords || flags.Top > 0 || flags.ApproxTop > 0,
+	}
+	c.trackWords = c.captureWords || flags.MinWordLen > 1
+	if flags.ShowUniqueWords || flags.Top > 0 {
+		c.counts.Freq = make(map[string]int64)
+	}
+	if flags.ApproxTop > 0 {
+		c.counts.ApproxTop = newTopSketch(flags.ApproxTop)
+	}
+	c.decodeRunes = c.isWordRune != nil || flags.ShowPrintable || flags.ShowControl || flags.ShowEmoji || flags.Script ||
+		flags.ShowGraphemes || flags.Readability || flags.SentenceTypes || flags.DistinctChars
+	if flags.DistinctChars {
+		c.counts.Runes = make(map[rune]bool)
+	}
+	if flags.Segment != "" {
+		c.segmenter = newSegmenter(flags.Segment, flags.segmentDict, c.wordRune, c.breakWord)
+	}
+	if flags.ShowGraphemes {
+		c.graphemes = &graphemeCounter{}
+	}
+	if flags.Readability || flags.SentenceTypes {
+		c.readability = newReadabilityCounter()
+	}
+	if flags.ShowLongest > 0 {
+		c.longest = newLongestLines(flags.ShowLongest)
+	}
+	if flags.matches != nil {
+		c.matches = &matchTally{re: flags.matches}
+	}
+	if flags.AdjacentDupes {
+		c.dupes = &dupeTracker{}
+	}
+	if flags.EdgeBlanks {
+		c.edges = newEdgeBlanks()
+	}
+	if flags.Script {
+		c.counts.Scripts = make(map[string]int64)
+		c.scriptCache = make(map[rune]string)
+	}
+	if flags.Preview {
+		c.preview = &linePreview{width: flags.PreviewWidth}
+	}
+	if flags.LineStats {
+		c.counts.LineStats = newLineStats()
+	}
+	if flags.WordLengthStats {
+		c.counts.WordLengths = newWordLengths()
+		c.wordLengths = &wordLengthTracker{stats: c.counts.WordLengths}
+	}
+	if flags.CountSubstr != "" {
+		c.substr = newSubstrCounter(flags.CountSubstr, flags.Overlapping)
+	}
+	if flags.patterns != nil {
+		c.patterns = newPatternCounter(flags.patterns)
+	}
+	if flags.UTF8Report {
+		c.utf8 = newUTF8Checker()
+	}
+	if flags.Fields != "" {
+		sep, _ := fieldSeparator(flags.Fields) // Checked by Flags.validate
+		c.fields = newFieldCounter(sep, -1, false)
+	}
+	if flags.CountColumn > 0 {
+		sep, _ := fieldSeparator(flags.FieldSep)
+		c.column = newFieldCounter(sep, flags.CountColumn-1, flags.Unique)
+	}
+	if flags.IndentStats {
+		c.counts.Indents = make(map[int64]int64)
+		c.indenting = true
+	}
+	c.fastLines = canCountLinesFast(c)
+	return c
+}
+
+// feed processes the next chunk of input.
+func (c *counter) feed(buf []byte) {
+	c.counts.Bytes += int64(len(buf))
+	if c.fastLines {
+		c.counts.Lines += countLines(buf)
+		return
+	}
+
+	for _, char := range buf {
+		// Count lines (efficiently check for newline)
+		eol := char == '\n'
+		pairTail := false // Is this the '\n' of a "\r\n" already counted at the '\r'?
+		if c.flags.AnyEOL {
+			// A '\r' always ends a line; a '\n' only does so when it is
+			// not the second half of a "\r\n" pair. prevCR carries over
+			// between chunks, so pairs split across reads are handled too.
+			pairTail = eol && c.prevCR
+			eol = char == '\r' || (eol && !pairTail)
+			c.prevCR = char == '\r'
+		}
+		if eol {
+			c.counts.Lines++
+		}
+
+		if c.flags.EOLReport {
+			if c.pendingCR {
+				if char == '\n' {
+					c.counts.EOLCRLF++
+				} else {
+					c.counts.EOLCR++
+				}
+			} else if char == '\n' {
+				c.counts.EOLLF++
+			}
+			c.pendingCR = char == '\r'
+		}
+
+		// Count UTF-8 characters: every byte except continuation bytes
+		// (10xxxxxx) starts a new character.
+		if c.countChars && char&0xC0 != 0x80 {
+			c.counts.Chars++
+		}
+
+		// Count words using a state machine
+		// Consider any Unicode space character as a separator.
+		// Cast byte to rune for unicode.IsSpace
+		isSpace := unicode.IsSpace(rune(char))
+		if c.splitBytes {
+			if isSpace {
+				c.counts.WhitespaceBytes++
+			} else {
+				c.counts.ContentBytes++
+			}
+		}
+		if c.isWordRune != nil {
+			// Words are counted by feedRunes instead
+		} else if c.flags.NoCollapseDelims {
+			if !pairTail {
+				c.trackDelims(char, eol, isSpace)
+			}
+		} else if isSpace {
+			if c.inWord && c.trackWords {
+				c.endWord()
+			}
+			c.inWord = false
+		} else {
+			// If we were not in a word before, and current char is not space,
+			// it marks the beginning of a new word.
+			if !c.inWord {
+				if c.flags.MinWordLen <= 1 {
+					c.counts.Words++ // Otherwise endWord decides
+				}
+				c.inWord = true
+			}
+			if c.trackWords {
+				if char&0xC0 != 0x80 {
+					c.wordLen++ // Not a UTF-8 continuation byte
+				}
+				if c.captureWords && c.belowWordCap() {
+					c.word = append(c.word, char)
+				}
+			}
+		}
+
+		if c.preview != nil && !pairTail {
+			if eol {
+				c.preview.endLine()
+			} else {
+				c.preview.add(char)
+			}
+		}
+
+		if c.trackLines && !pairTail {
+			if eol {
+				c.endLine()
+			} else {
+				c.lineLen++
+				if char&0xC0 != 0x80 {
+					c.lineChars++
+				}
+				if !isSpace {
+					c.lineBlank = false
+				}
+			}
+		}
 
-		// Always count bytes read, even if there's an error (like EOF)
-		counts.Bytes += int64(n)
+		if c.counts.Indents != nil && !pairTail {
+			c.trackIndent(char, eol)
+		}
+		if c.wordLengths != nil && !pairTail {
+			c.wordLengths.add(char, eol, isSpace)
+		}
+		if c.edges != nil && !pairTail {
+			c.edges.add(eol, isSpace)
+		}
+		if c.measureWidth && !pairTail {
+			c.trackWidth(char, eol)
+		}
+		if c.fields != nil && !pairTail {
+			c.fields.add(char, eol)
+		}
+		if c.column != nil && !pairTail {
+			c.column.add(char, eol)
+		}
+	}
 
-		// Process the chunk that was just read
-		for i := 0; i < n; i++ {
-			char := buf[i]
+	if c.decodeRunes {
+		c.feedRunes(buf)
+	}
+	if c.substr != nil {
+		c.substr.feed(buf)
+	}
+	if c.patterns != nil {
+		c.patterns.feed(buf)
+	}
+	if c.utf8 != nil {
+		c.utf8.feed(buf)
+	}
+	if c.matches != nil {
+		c.matches.feed(buf)
+	}
+	if c.dupes != nil {
+		c.dupes.feed(buf)
+	}
+}
+
+// finish flushes any state still pending at the end of the input.
+func (c *counter) finish() {
+	if c.flags.RecordLength > 0 {
+		c.counts.Lines = recordCount(c.counts.Bytes, c.flags.RecordLength, c.flags.PartialRecord)
+	}
+	if c.decodeRunes {
+		c.finishRunes()
+	}
+	if c.segmenter != nil {
+		c.segmenter.flush() // The words of a run the input ended in
+	}
+	if c.graphemes != nil {
+		c.counts.Graphemes = c.graphemes.count
+	}
+	if c.readability != nil {
+		c.counts.Readability = c.readability.finish()
+	}
+	if c.inWord && c.trackWords {
+		c.endWord() // The input ended in the middle of a word
+	}
+	if c.delimLine {
+		c.counts.Words += c.delims + 1 // A final line without a terminator
+	}
+	if c.preview != nil {
+		c.counts.Preview = c.preview.finish()
+	}
+	if c.substr != nil {
+		c.counts.Substr = c.substr.count
+	}
+	if c.patterns != nil {
+		c.counts.Patterns = c.patterns.counts
+	}
+	if c.matches != nil {
+		c.matches.finish()
+		c.counts.Matches = c.matches.count
+	}
+	if c.dupes != nil {
+		c.dupes.finish()
+		c.counts.Repeats, c.counts.LongestRun = c.dupes.repeats, c.dupes.longestRun
+	}
+	if c.utf8 != nil {
+		c.utf8.finish()
+		c.counts.UTF8 = c.utf8.report
+	}
+	if c.pendingCR {
+		c.counts.EOLCR++ // A '\r' right at the end of the input
+	}
+	if c.counts.LineStats != nil && c.lineLen > 0 {
+		c.counts.LineStats.Add(c.lineChars) // A final line without a terminator
+	}
+	if c.wordLengths != nil {
+		c.wordLengths.endLine() // Likewise
+	}
+	if c.edges != nil {
+		c.edges.finish()
+		c.counts.LeadingBlanks, c.counts.TrailingBlanks = c.edges.leading, c.edges.trailing
+	}
+	if c.measureWidth && c.lineWidth > 0 {
+		c.endWidth(c.counts.Lines + 1) // Likewise, a line not counted in Lines
+	}
+	if c.longest != nil {
+		c.counts.Longest = c.longest.sorted()
+	}
+	if c.fields != nil {
+		c.fields.endLine()
+		c.counts.Fields = &c.fields.stats
+	}
+	if c.column != nil {
+		c.column.endLine()
+		c.counts.ColumnValues = c.column.values
+		c.counts.ColumnDistinct = c.column.distinct
+	}
+}
+
+// endLine classifies the line that was just terminated and resets the
+// per-line state. Without -any-eol a '\r' is ordinary whitespace, so an
+// empty CRLF line counts as whitespace-only rather than truly empty.
+func (c *counter) endLine() {
+	if c.lineLen == 0 {
+		c.counts.TrulyEmpty++
+	} else if c.lineBlank {
+		c.counts.WhitespaceOnly++
+	}
+	if c.counts.LineStats != nil {
+		c.counts.LineStats.Add(c.lineChars)
+	}
+	c.lineLen = 0
+	c.lineChars = 0
+	c.lineBlank = true
+}
+
+// count performs the counting operation on the given reader.
+// It's optimized by reading in large chunks and processing the buffer.
+func count(reader io.Reader, flags Flags) (Counts, error) {
+	// Use bufio.Reader with a specified large buffer size for performance.
+	// -direct-read reads straight into buf instead, to measure what the
+	// extra layer costs.
+	var sample *sampleReader
+	if flags.Sample > 1 {
+		sample = newSampleReader(reader, flags.Sample, bufferSize)
+		reader = sample
+	}
+	var br io.Reader = reader
+	if !flags.DirectRead {
+		br = bufio.NewReaderSize(reader, bufferSize)
+	}
+	buf := make([]byte, bufferSize) // Reusable buffer for Read calls
+	c := newCounter(flags)
+	custom := counters.New()
+
+	for {
+		// Read a chunk from the buffered reader into our local buffer.
+		// This minimizes the number of underlying system calls.
+		n, err := br.Read(buf)
+
+		// Always process bytes read, even if there's an error (like EOF)
+		c.Feed(buf[:n])
+		custom.Feed(buf[:n])
+
+		// Handle read errors
+		if err != nil {
+			if err == io.EOF {
+				break // End of file reached, exit loop normally
+			}
+			// A failure to write the -tee copy is not a read error
+			var teeErr *teeWriteError
+			if errors.As(err, &teeErr) {
+				return c.counts, teeErr
+			}
+			// An actual read error occurred
+			return c.counts, fmt.Errorf("error reading input: %w", err)
+		}
+	}
+
+	counts := c.Result().(Counts)
+	counts.Custom = custom.Results()
+	if sample != nil {
+		scaleSample(&counts, sample)
+	}
+	return counts, nil
+}
+
+// limitRange restricts the reader to the byte range selected by flags.Offset
+// and flags.Length. Seekable inputs (regular files) jump straight to the
+// offset; anything else (pipes, terminals) has the leading bytes discarded.
+func limitRange(reader io.Reader, flags Flags) (io.Reader, error) {
+	if flags.Offset > 0 {
+		seeked := false
+		if seeker, ok := reader.(io.Seeker); ok {
+			// Seeking a pipe fails with ESPIPE, in which case we fall back to skipping.
+			if _, err := seeker.Seek(flags.Offset, io.SeekStart); err == nil {
+				seeked = true
+			}
+		}
+		if !seeked {
+			if _, err := io.CopyN(io.Discard, reader, flags.Offset); err != nil && err != io.EOF {
+				return nil, fmt.Errorf("error skipping to offset %d: %w", flags.Offset, err)
+			}
+		}
+	}
+	if flags.Length >= 0 {
+		reader = io.LimitReader(reader, flags.Length)
+	}
+	return reader, nil
+}
+
+// openInput opens the named input ("-" meaning standard input) and wraps
+// it with wrapInput. The returned close function releases the underlying
+// file and must be called once the reader is no longer needed.
+func openInput(filename string, flags Flags) (io.Reader, func() error, error) {
+	var reader io.Reader = os.Stdin
+	closer := func() error { return nil } // Standard input is never closed
+	if filename != "-" {
+		file, err := os.Open(filename)
+		if err != nil {
+			return nil, nil, err
+		}
+		reader = file
+		closer = file.Close
+	}
+
+	reader, err := wrapInput(reader, flags)
+	if err != nil {
+		closer()
+		return nil, nil, err
+	}
+	return reader, closer, nil
+}
+
+// wrapInput applies the byte range selected by flags to an opened input,
+// throttles it with -rate-limit and copies it to the -tee target.
+func wrapInput(reader io.Reader, flags Flags) (io.Reader, error) {
+	reader, err := limitRange(reader, flags)
+	if err != nil {
+		return nil, err
+	}
+	if flags.RateLimit > 0 {
+		reader = newRateLimitedReader(reader, flags.RateLimit)
+	}
+	return teeInput(reader, flags), nil
+}
+
+// countFile opens the named input ("-" meaning standard input) and counts it.
+// With -state-file only the part of a file not counted by a previous run is
+// read, and the state is advanced once counting succeeds.
+func countFile(filename string, flags Flags) (Counts, error) {
+	if flags.state != nil && filename != "-" {
+		offset, size, err := flags.state.begin(filename)
+		if err != nil {
+			return Counts{}, err
+		}
+		state := flags.state
+		flags.state = nil
+		flags.Offset, flags.Length = offset, size-offset
+		counts, err := countFile(filename, flags)
+		if err == nil {
+			state.commit(filename, size)
+		}
+		return counts, err
+	}
+
+	reader, closer, err := openInput(filename, flags)
+	if err != nil {
+		return Counts{}, err
+	}
+	// Ensure file is closed even if counting fails partially
+	defer closer()
+	return countOpened(reader, filename, flags)
+}
+
+// countFD counts the input behind a file descriptor inherited from the
+// parent process, e.g. by shell redirection (3<file) or process
+// substitution. Such descriptors are often pipes; they are read like
+// standard input, so -offset skips bytes instead of seeking.
+func countFD(fd int, flags Flags) (Counts, error) {
+	file := os.NewFile(uintptr(fd), fdName(fd))
+	if file == nil {
+		return Counts{}, fmt.Errorf("invalid file descriptor %d", fd)
+	}
+	defer file.Close()
+	if _, err := file.Stat(); err != nil {
+		return Counts{}, err // Most likely a descriptor that isn't open
+	}
+
+	reader, err := wrapInput(file, flags)
+	if err != nil {
+		return Counts{}, err
+	}
+	return countOpened(reader, file.Name(), flags)
+}
+
+// fdName is the name an input read with -fd is reported under.
+func fdName(fd int) string {
+	return fmt.Sprintf("/dev/fd/%d", fd)
+}
+
+// countOpened counts an opened input, piping it through the -pipe-through
+// command, decoding base64 with -base64-decode and decompressing it with
+// -decompress first, in this order.
+func countOpened(reader io.Reader, filename string, flags Flags) (Counts, error) {
+	if flags.PipeThrough != "" {
+		piped, err := pipeThrough(reader, flags.PipeThrough)
+		if err != nil {
+			return Counts{}, err
+		}
+		defer piped.Close()
+		reader = piped
+	}
+	if flags.Base64Decode {
+		reader = decodeBase64(reader, flags.Base64URL)
+	}
+
+	// -dedup hashes exactly the bytes that are counted
+	var digest *digestReader
+	if flags.Dedup {
+		digest = newDigestReader(reader)
+		reader = digest
+	}
+
+	// With -decompress the byte count describes the decompressed data,
+	// while the bytes actually read from the input are tallied underneath
+	var compressed *countingReader
+	if flags.Decompress {
+		compressed = &countingReader{reader: reader}
+		var err error
+		if reader, err = decompress(compressed); err != nil {
+			return Counts{}, err
+		}
+	}
+
+	if flags.SkipBinary {
+		var err error
+		if reader, err = checkText(reader, flags.TextThreshold); err != nil {
+			return Counts{}, err
+		}
+	}
+
+	// The mark is looked for in the content as counted, after any -decompress
+	var bom *bomReader
+	if flags.ReportBOM {
+		bom = &bomReader{reader: reader}
+		reader = bom
+	}
+
+	counts, err := countDecoded(reader, filename, flags)
+	if bom != nil {
+		counts.BOM = bom.bom()
+	}
+	if compressed != nil {
+		counts.Compressed = compressed.n
+	}
+	if digest != nil {
+		counts.Digest = digest.digest()
+	}
+	return counts, err
+}
+
+// countDecoded counts the input after transcoding it to UTF-8 according to
+// -encoding, removing markup with -strip-tags and normalizing it with -nfc
+// or -nfd. Lines, words and characters describe the resulting text, while
+// the byte count still reflects the raw input. Only -line-range and -match,
+// which select part of the text, make the byte count describe that part
+// instead.
+func countDecoded(reader io.Reader, filename string, flags Flags) (Counts, error) {
+	decode := flags.Encoding != "" && flags.Encoding != encodingUTF8
+	normalize := flags.NFC || flags.NFD
+	selected := flags.LineRange != "" || flags.match != nil
+	if !decode && !flags.StripTags && !normalize && !selected {
+		return count(reader, flags)
+	}
+
+	raw := &countingReader{reader: reader}
+	reader = raw
+	warn := func(msg string) {
+		fmt.Fprintf(os.Stderr, "%s: %s: warning: %s\n", os.Args[0], filename, msg)
+	}
+	var utf16 *utf16Reader
+	if decode {
+		decoded, err := decodeInput(reader, flags.Encoding, warn)
+		if err != nil {
+			return Counts{}, err
+		}
+		reader = decoded
+		utf16, _ = decoded.(*utf16Reader)
+	}
+	if flags.StripTags {
+		reader = newTagStripper(reader)
+	}
+	if normalize {
+		reader = newNormReader(reader, flags.NFC)
+	}
+	if flags.LineRange != "" {
+		from, to, _ := parseLineRange(flags.LineRange) // Checked by Flags.validate
+		reader = newLineRangeFilter(reader, from, to)
+	}
+	var match *matchFilter
+	if flags.match != nil {
+		match = newMatchFilter(reader, flags.match, flags.OnlyMatching)
+		reader = match
+	}
+
+	counts, err := count(reader, flags)
+	if !selected {
+		counts.Bytes = raw.n
+	} else if match != nil {
+		// Take the blanks and newlines -only-matching added back out
+		counts.Bytes -= match.inserted
+		if counts.Chars > 0 {
+			counts.Chars -= match.inserted
+		}
+	}
+	if utf16 != nil {
+		counts.LoneSurrogates = utf16.lone
+		if utf16.lone > 0 {
+			warn(fmt.Sprintf("%d unpaired UTF-16 surrogates replaced by U+FFFD", utf16.lone))
+		}
+	}
+	return counts, err
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	reader io.Reader
+	n      int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// lazyInput is a reader over a named input that is only opened on the first
+// Read and closed again at its end, so that merging many files keeps just
+// one of them open at a time. Errors are handed to onError and end the
+// input early, letting the merged stream continue with the next one.
+type lazyInput struct {
+	filename string
+	flags    Flags
+	onError  func(filename string, err error)
+
+	reader     io.Reader
+	closer     func() error
+	compressed *countingReader // Raw bytes read with -decompress
+	done       bool
+}
+
+func (l *lazyInput) Read(p []byte) (int, error) {
+	if l.done {
+		return 0, io.EOF
+	}
+	if l.reader == nil {
+		reader, closer, err := openInput(l.filename, l.flags)
+		if err != nil {
+			l.done = true
+			l.onError(l.filename, err)
+			return 0, io.EOF
+		}
+		if l.flags.PipeThrough != "" {
+			piped, err := pipeThrough(reader, l.flags.PipeThrough)
+			if err != nil {
+				closer()
+				l.done = true
+				l.onError(l.filename, err)
+				return 0, io.EOF
+			}
+			reader, closer = piped, chainClose(piped.Close, closer)
+		}
+		if l.flags.Base64Decode {
+			reader = decodeBase64(reader, l.flags.Base64URL)
+		}
+		if l.flags.Decompress {
+			l.compressed = &countingReader{reader: reader}
+			if reader, err = decompress(l.compressed); err != nil {
+				closer()
+				l.done = true
+				l.onError(l.filename, err)
+				return 0, io.EOF
+			}
+		}
+		l.reader, l.closer = reader, closer
+	}
+
+	n, err := l.reader.Read(p)
+	if err != nil {
+		l.done = true
+		l.closer()
+		if err != io.EOF {
+			l.onError(l.filename, fmt.Errorf("error reading input: %w", err))
+		}
+		err = io.EOF
+	}
+	return n, err
+}
+
+// chainClose returns a closer running first and then second.
+func chainClose(first, second func() error) func() error {
+	return func() error {
+		first()
+		return second()
+	}
+}
+
+// countMerged counts all named inputs as if they were a single concatenated
+// stream. Unlike the total line, a word running across the end of one file
+// into the start of the next is counted once.
+func countMerged(filenames []string, flags Flags, onError func(filename string, err error)) (Counts, error) {
+	inputs := make([]*lazyInput, len(filenames))
+	readers := make([]io.Reader, len(filenames))
+	for i, filename := range filenames {
+		inputs[i] = &lazyInput{filename: filename, flags: flags, onError: onError}
+		readers[i] = inputs[i]
+	}
+
+	counts, err := countDecoded(io.MultiReader(readers...), flags.MergeLabel, flags)
+	for _, input := range inputs {
+		if input.compressed != nil {
+			counts.Compressed += input.compressed.n
+		}
+	}
+	return counts, err
+}
+
+// runningTotalLabel is printed on the -running-total lines.
+const runningTotalLabel = "(running total)"
+
+// Use a consistent width for alignment (e.g., 8 characters)
+const columnWidth = 8
+
+// selectedCounts returns the values of the enabled count columns, in the
+// order they are printed.
+func selectedCounts(counts Counts, flags Flags) []int64 {
+	var values []int64
+	if flags.ShowLines {
+		values = append(values, counts.Lines)
+	}
+	if flags.ShowWords {
+		values = append(values, counts.Words)
+	}
+	if flags.ShowGraphemes {
+		values = append(values, counts.Graphemes)
+	}
+	if flags.ShowChars {
+		values = append(values, counts.Chars)
+	}
+	if flags.ShowBytes {
+		values = append(values, counts.Bytes)
+	}
+	if flags.ShowTrulyEmpty {
+		values = append(values, counts.TrulyEmpty)
+	}
+	if flags.ShowWhitespaceOnly {
+		values = append(values, counts.WhitespaceOnly)
+	}
+	if flags.ShowPrintable {
+		values = append(values, counts.Printable)
+	}
+	if flags.ShowControl {
+		values = append(values, counts.Control)
+	}
+	if flags.ShowWhitespaceBytes {
+		values = append(values, counts.WhitespaceBytes)
+	}
+	if flags.ShowContentBytes {
+		values = append(values, counts.ContentBytes)
+	}
+	if flags.ShowEmoji {
+		values = append(values, counts.Emoji)
+	}
+	if flags.CountSubstr != "" {
+		values = append(values, counts.Substr)
+	}
+	if flags.CountColumn > 0 {
+		values = append(values, counts.ColumnValues)
+		if flags.Unique {
+			values = append(values, int64(len(counts.ColumnDistinct)))
+		}
+	}
+	if flags.ShowMaxLine {
+		values = append(values, counts.MaxLine)
+	}
+	if flags.ShowMaxLineBytes {
+		values = append(values, counts.MaxLineBytes)
+	}
+	if flags.OverLength > 0 {
+		values = append(values, counts.OverLength)
+	}
+	if flags.ShowUniqueWords {
+		values = append(values, int64(len(counts.Freq)))
+	}
+	if flags.DistinctChars {
+		values = append(values, int64(len(counts.Runes)))
+	}
+	if flags.Stopwords != "" {
+		values = append(values, counts.FilteredWords)
+	}
+	if flags.Decompress {
+		values = append(values, counts.Compressed)
+	}
+	if flags.ColumnOrder != "" {
+		perm := columnOrder(canonicalNames(flags), flags.ColumnOrder)
+		ordered := make([]int64, len(values))
+		for i, p := range perm {
+			ordered[i] = values[p]
+		}
+		values = ordered
+	}
+	return values
+}
+
+// allZero reports whether every enabled count column of counts is zero.
+func allZero(counts Counts, flags Flags) bool {
+	for _, value := range selectedCounts(counts, flags) {
+		if value != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// selectedNames returns the short names of the enabled count columns, in
+// the same order as selectedCounts.
+func selectedNames(flags Flags) []string {
+	names := canonicalNames(flags)
+	if flags.ColumnOrder != "" {
+		perm := columnOrder(names, flags.ColumnOrder)
+		ordered := make([]string, len(names))
+		for i, p := range perm {
+			ordered[i] = names[p]
+		}
+		names = ordered
+	}
+	return names
+}
+
+// canonicalNames returns the names of the enabled count columns in their
+// usual order, before -column-order is applied.
+func canonicalNames(flags Flags) []string {
+	var names []string
+	if flags.ShowLines {
+		names = append(names, "lines")
+	}
+	if flags.ShowWords {
+		names = append(names, "words")
+	}
+	if flags.ShowGraphemes {
+		names = append(names, "graphemes")
+	}
+	if flags.ShowChars {
+		names = append(names, "chars")
+	}
+	if flags.ShowBytes {
+		names = append(names, "bytes")
+	}
+	if flags.ShowTrulyEmpty {
+		names = append(names, "truly_empty")
+	}
+	if flags.ShowWhitespaceOnly {
+		names = append(names, "whitespace_only")
+	}
+	if flags.ShowPrintable {
+		names = append(names, "printable")
+	}
+	if flags.ShowControl {
+		names = append(names, "control")
+	}
+	if flags.ShowWhitespaceBytes {
+		names = append(names, "whitespace_bytes")
+	}
+	if flags.ShowContentBytes {
+		names = append(names, "content_bytes")
+	}
+	if flags.ShowEmoji {
+		names = append(names, "emoji")
+	}
+	if flags.CountSubstr != "" {
+		names = append(names, "substr")
+	}
+	if flags.CountColumn > 0 {
+		names = append(names, "column_values")
+		if flags.Unique {
+			names = append(names, "column_distinct")
+		}
+	}
+	if flags.ShowMaxLine {
+		names = append(names, "max_line")
+	}
+	if flags.ShowMaxLineBytes {
+		names = append(names, "max_line_bytes")
+	}
+	if flags.OverLength > 0 {
+		names = append(names, "over_length")
+	}
+	if flags.ShowUniqueWords {
+		names = append(names, "unique_words")
+	}
+	if flags.DistinctChars {
+		names = append(names, "distinct_chars")
+	}
+	if flags.Stopwords != "" {
+		names = append(names, "filtered_words")
+	}
+	if flags.Decompress {
+		names = append(names, "compressed")
+	}
+	return names
+}
+
+// columnWidths returns the width of each enabled count column, in the same
+// order as selectedCounts. With -headers a column is widened to fit its name.
+func columnWidths(flags Flags) []int {
+	names := selectedNames(flags)
+	widths := make([]int, len(names))
+	for i, name := range names {
+		widths[i] = columnWidth
+		if flags.Headers && len(name)+1 > columnWidth {
+			widths[i] = len(name) + 1
+		}
+	}
+	return widths
+}
+
+// formatColumn right-aligns a formatted value in a column of width
+// characters. A value as wide as the column, or wider, gets a leading space
+// instead, to keep it apart from the value before it.
+func formatColumn(formatted string, width int) string {
+	if len(formatted) >= width {
+		return " " + formatted
+	}
+	return fmt.Sprintf("%*s", width, formatted)
+}
+
+// formatHeader formats the -headers row naming the columns printed by
+// formatOutput, or by formatNormalized with -normalize.
+func formatHeader(flags Flags) string {
+	var parts []string
+	widths := columnWidths(flags)
+	names := selectedNames(flags)
+	for i, name := range names {
+		parts = append(parts, fmt.Sprintf("%*s", widths[i], name))
+	}
+	if flags.Decompress {
+		parts = append(parts, fmt.Sprintf("%*s", columnWidth, "ratio"))
+	}
+	if flags.BytesPerLine {
+		parts = append(parts, fmt.Sprintf("%*s", columnWidth, "b/line"))
+	}
+	if flags.Normalize {
+		for i := range names {
+			parts = append(parts, fmt.Sprintf("%*s", widths[i], "%"))
+		}
+	}
+	parts = append(parts, " filename")
+	return strings.Join(parts, "")
+}
+
+// formatOutput formats the counts according to the selected flags for printing.
+// It mimics the right-aligned output of standard wc.
+func formatOutput(counts Counts, flags Flags, filename string) string {
+	var parts []string
+
+	widths := columnWidths(flags)
+	for i, value := range selectedCounts(counts, flags) {
+		parts = append(parts, formatColumn(formatCount(value, flags), widths[i]))
+	}
+
+	// The compression ratio follows the compressed size
+	if flags.Decompress {
+		if ratio, ok := compressionRatio(counts); ok {
+			parts = append(parts, fmt.Sprintf("%*.1f%%", columnWidth-1, ratio))
+		} else {
+			parts = append(parts, fmt.Sprintf("%*s", columnWidth, "-"))
+		}
+	}
+	if flags.BytesPerLine {
+		if perLine, ok := bytesPerLine(counts); ok {
+			parts = append(parts, fmt.Sprintf("%*.1f", columnWidth, perLine))
+		} else {
+			parts = append(parts, fmt.Sprintf("%*s", columnWidth, "-"))
+		}
+	}
+
+	// Add filename if provided
+	if filename != "" {
+		if flags.SafeNames {
+			filename = escapeName(filename)
+		}
+		// Add a space separator before the filename
+		parts = append(parts, " "+filename)
+	}
+
+	// The preview comes last, as it may be of any length
+	if flags.Preview && counts.Preview != nil {
+		parts = append(parts, formatPreview(counts.Preview))
+	}
+
+	return strings.Join(parts, "")
+}
+
+// formatNormalized formats the counts like formatOutput, followed by each
+// enabled count as a percentage of the grand total. Columns whose total is
+// zero have no meaningful share and are shown as "-".
+func formatNormalized(counts, total Counts, flags Flags, filename string) string {
+	parts := []string{formatOutput(counts, flags, "")}
+
+	widths := columnWidths(flags)
+	totals := selectedCounts(total, flags)
+	for i, value := range selectedCounts(counts, flags) {
+		if totals[i] == 0 {
+			parts = append(parts, fmt.Sprintf("%*s", widths[i], "-"))
+			continue
+		}
+		percent := float64(value) / float64(totals[i]) * 100
+		parts = append(parts, fmt.Sprintf("%*.1f%%", widths[i]-1, percent))
+	}
+
+	if filename != "" && flags.SafeNames {
+		filename = escapeName(filename)
+	}
+	if filename != "" {
+		parts = append(parts, " "+filename)
+	}
+	if flags.Preview && counts.Preview != nil {
+		parts = append(parts, formatPreview(counts.Preview))
+	}
+
+	return strings.Join(parts, "")
+}
+
+// printResult prints the output line for one result, followed by any
+// detail lines the selected reports add for it.
+func printResult(line string, counts Counts, flags Flags) {
+	fmt.Println(line)
+	for _, detail := range formatDetails(counts, flags) {
+		fmt.Println(detail)
+	}
+}
+
+// formatDetails returns the indented report lines printed below a result,
+// for breakdowns that do not fit into a single count column.
+func formatDetails(counts Counts, flags Flags) []string {
+	var details []string
+	if flags.EOLReport {
+		details = append(details, formatEOLReport(counts))
+	}
+	if flags.LineStats {
+		details = append(details, formatLineStats(counts.LineStats))
+	}
+	if flags.WordLengthStats {
+		details = append(details, formatWordLengths(counts.WordLengths))
+	}
+	if flags.IndentStats {
+		details = append(details, formatIndentStats(counts.Indents))
+	}
+	if flags.Script {
+		details = append(details, formatScripts(counts.Scripts))
+	}
+	if counts.UTF8 != nil {
+		details = append(details, formatUTF8Report(counts.UTF8))
+	}
+	if flags.Fields != "" {
+		details = append(details, formatFields(counts.Fields))
+	}
+	if flags.Readability {
+		details = append(details, formatReadability(counts.Readability))
+	}
+	if flags.SentenceTypes {
+		details = append(details, formatSentenceTypes(counts.Readability))
+	}
+	if flags.ListChars {
+		details = append(details, formatChars(counts.Runes))
+	}
+	if flags.MatchesPerLine != "" {
+		details = append(details, fmt.Sprintf("    matches: %s of %q", formatCount(counts.Matches, flags), flags.MatchesPerLine))
+	}
+	if flags.patterns != nil {
+		details = append(details, formatPatterns(flags.patterns, counts.Patterns, flags)...)
+	}
+	if flags.AdjacentDupes {
+		details = append(details, formatDupes(counts, flags))
+	}
+	if flags.EdgeBlanks {
+		details = append(details, formatEdgeBlanks(counts, flags))
+	}
+	if len(counts.OverLengthLines) > 0 {
+		details = append(details, formatOverLength(counts.OverLengthLines))
+	}
+	if len(counts.Longest) > 0 {
+		details = append(details, formatLongest(counts.Longest))
+	}
+	if counts.BOM != "" {
+		details = append(details, "    bom: "+counts.BOM)
+	}
+	if counts.ExitStatus != nil {
+		details = append(details, fmt.Sprintf("    exit status: %d", *counts.ExitStatus))
+	}
+	if counts.Baseline != nil {
+		details = append(details, formatBaseline(counts.Baseline, flags))
+	}
+	if flags.Sample > 1 {
+		details = append(details, formatSample(counts))
+	}
+	if flags.MaxWordCap > 0 {
+		details = append(details, fmt.Sprintf("    capped words: %s longer than %d characters, tallied truncated", formatCount(counts.CappedWords, flags), flags.MaxWordCap))
+	}
+	if flags.Top > 0 {
+		details = append(details, formatTopWords(counts.Freq, flags.Top, flags.MinFrequency)...)
+	}
+	if counts.ApproxTop != nil {
+		details = append(details, formatApproxTop(counts.ApproxTop)...)
+	}
+	details = append(details, formatCustomResults(counts.Custom)...)
+	return details
+}
+
+// defineFlags defines the command line options on fs, storing their values
+// in flags. It is shared by the command line and the per-command options of
+// -server.
+func defineFlags(fs *flag.FlagSet, flags *Flags) {
+	fs.BoolVar(&flags.ShowLines, "l", false, "print the newline counts")
+	fs.BoolVar(&flags.ShowWords, "w", false, "print the word counts")
+	fs.BoolVar(&flags.ShowChars, "m", false, "print the character counts")
+	fs.BoolVar(&flags.ShowGraphemes, "graphemes", false, "print the counts of grapheme clusters (user-perceived characters)")
+	fs.BoolVar(&flags.Widths, "widths", false, "print grapheme clusters, characters and bytes side by side (-graphemes -m -c)")
+	fs.BoolVar(&flags.ShowMaxLine, "L", false, "print the width of the widest line (tabs expanded to -tab-width)")
+	fs.BoolVar(&flags.ShowMaxLineBytes, "widest-line-bytes", false, "print the length in bytes of the longest line (next to -L's display width if both are given)")
+	fs.BoolVar(&flags.BytesPerLine, "bytes-per-line", false, "also print the average number of bytes per line, after the counts")
+	fs.BoolVar(&flags.ShowBytes, "c", false, "print the byte counts")
+	fs.BoolVar(&flags.BytesTotal, "bytes-total", false, "print only the total byte count of all inputs, as a bare number")
+	fs.BoolVar(&flags.Compare, "compare", false, "count exactly two files and print the change from the first to the second, absolute and in percent")
+	fs.StringVar(&flags.WatchDir, "watch-dir", "", "keep counting each file created or modified in `DIR` until interrupted")
+	fs.DurationVar(&flags.WatchInterval, "watch-interval", time.Second, "with -watch-dir, how often to look for changes; a file is counted once unchanged for this `DURATION`")
+	fs.BoolVar(&flags.Headers, "headers", false, "print a row of column names above the counts")
+	fs.BoolVar(&flags.Prose, "prose", false, "print each result as a sentence, e.g. \"notes.txt has 42 lines, 300 words, and 1,800 bytes.\"")
+	fs.BoolVar(&flags.Prometheus, "prometheus", false, "print the counts as Prometheus metrics, e.g. gowc_lines_total{file=\"foo.txt\"} 42, once everything is counted")
+	fs.BoolVar(&flags.KV, "kv", false, "print each result as key=value pairs, e.g. \"lines=10 words=50 bytes=300 file=foo.txt\"")
+	fs.BoolVar(&flags.Thousands, "thousands", false, "group the digits of printed counts by thousands (1,800)")
+	fs.BoolVar(&flags.HideEmpty, "hide-empty", false, "do not print files whose selected counts are all zero (they still count towards the total)")
+	fs.BoolVar(&flags.LineStats, "line-stats", false, "print the mean, median and standard deviation of line lengths below each result")
+	fs.BoolVar(&flags.WordLengthStats, "word-length-stats", false, "print the mean, standard deviation and a histogram of the lines' average word lengths below each result, e.g. for stylometry")
+	fs.StringVar(&flags.Fields, "fields", "", "print the fewest and most fields per line, split on `SEP` (escapes like \\t allowed), below each result, flagging ragged tables")
+	fs.BoolVar(&flags.Readability, "readability", false, "print sentences per paragraph, words per sentence and an approximate Flesch reading-ease score below each result")
+	fs.BoolVar(&flags.SentenceTypes, "sentence-types", false, "print how many sentences end in '.', '!' and '?' below each result")
+	fs.BoolVar(&flags.IndentStats, "indent-stats", false, "print a histogram of the leading whitespace widths of non-blank lines below each result")
+	fs.Int64Var(&flags.OverLength, "over-length", 0, "print the number of lines wider than `N` columns and exit 1 if there are any (0 to disable)")
+	fs.BoolVar(&flags.ListOverLength, "list-over-length", false, "with -over-length, list the line numbers of those lines below each result")
+	fs.IntVar(&flags.ShowLongest, "show-longest", 0, "list the line numbers and widths of the `N` widest lines below each result, widest first")
+	fs.IntVar(&flags.TabWidth, "tab-width", 8, "expand tabs to multiples of `N` columns when measuring widths")
+	fs.BoolVar(&flags.Preview, "preview", false, "print the first and last line of each input after the counts")
+	fs.IntVar(&flags.PreviewWidth, "preview-width", 40, "with -preview, show at most `N` characters of each line")
+	fs.BoolVar(&flags.SortByName, "sort-by-name", false, "print the results sorted by filename across all inputs")
+	fs.BoolVar(&flags.RunningTotal, "running-total", false, "after each result, print the total of the inputs counted so far on a \"(running total)\" line")
+	fs.BoolVar(&flags.Averages, "averages", false, "after the total, print the average of each count per input")
+	fs.BoolVar(&flags.NoTotalOnError, "no-total-on-error", false, "print no total (or averages) if any input could not be counted, rather than one that leaves it out")
+	fs.BoolVar(&flags.Normalize, "normalize", false, "also print each count as a percentage of the total across all files")
+	fs.BoolVar(&flags.ShowTrulyEmpty, "truly-empty", false, "print the counts of completely empty lines")
+	fs.BoolVar(&flags.ShowWhitespaceOnly, "whitespace-only", false, "print the counts of lines containing only whitespace")
+	fs.BoolVar(&flags.ShowPrintable, "printable", false, "print the counts of printable characters (unicode.IsPrint)")
+	fs.BoolVar(&flags.ShowControl, "control", false, "print the counts of control characters, including newlines and tabs")
+	fs.BoolVar(&flags.ShowWhitespaceBytes, "whitespace-bytes", false, "print the counts of whitespace bytes")
+	fs.BoolVar(&flags.ShowContentBytes, "content-bytes", false, "print the counts of non-whitespace bytes")
+	fs.BoolVar(&flags.ShowEmoji, "emoji", false, "print the counts of emoji and other symbols, counting joined sequences and flags once")
+	fs.BoolVar(&flags.Script, "script", false, "print the number of characters in each Unicode script (Latin, Cyrillic, Han, ...) below each result, most frequent first")
+	fs.BoolVar(&flags.UTF8Report, "utf8-report", false, "print the malformed UTF-8 below each result, by kind with the offset of the first of each")
+	fs.BoolVar(&flags.JSON, "json", false, "print the counts as a JSON document")
+	fs.BoolVar(&flags.JSONPretty, "json-pretty", false, "with -json, indent the document for readability (implies -json)")
+	fs.BoolVar(&flags.JSONAllFields, "json-all-fields", false, "with -json, always emit every count key (implies -json; always counts characters)")
+	fs.BoolVar(&flags.JSONErrors, "json-errors", false, "with -json, report inputs that cannot be counted as {\"filename\", \"error\"} entries in the document instead of on stderr, and add \"ok\" (implies -json)")
+	fs.BoolVar(&flags.JSONStreamArray, "json-stream-array", false, "print the counts as a JSON array written element by element as each input is counted, the total last")
+	fs.BoolVar(&flags.AnyEOL, "any-eol", false, "treat \\r, \\n and \\r\\n each as one line terminator")
+	fs.BoolVar(&flags.AnyEOL, "mac", false, "alias for -any-eol (classic Mac \\r line endings)")
+	fs.StringVar(&flags.Stopwords, "stopwords", "", "load a newline-separated stopword list from `FILE` and also print the word counts without them")
+	fs.BoolVar(&flags.ShowUniqueWords, "unique-words", false, "print the counts of distinct words (case-folded, without stopwords)")
+	fs.BoolVar(&flags.DistinctChars, "distinct-chars", false, "print the counts of distinct characters (the size of the alphabet)")
+	fs.BoolVar(&flags.ListChars, "list-chars", false, "with -distinct-chars, also list the distinct characters below each result")
+	fs.IntVar(&flags.Top, "top", 0, "list the `N` most frequent words (case-folded, without stopwords) below each result")
+	fs.StringVar(&flags.Baseline, "baseline", "", "compare every result with the same input in `FILE`, the -json output of an earlier run, and print the change")
+	fs.Int64Var(&flags.MinFrequency, "min-frequency", 0, "leave words occurring fewer than `N` times out of the -top list")
+	fs.IntVar(&flags.ApproxTop, "approx-top", 0, "like -top, but estimate the `N` most frequent words in bounded memory (counts may be slightly too high)")
+	fs.StringVar(&flags.CountSubstr, "count-substr", "", "also print the number of occurrences of the byte string `STR`")
+	fs.StringVar(&flags.Patterns, "patterns", "", "load byte strings from `FILE`, one per line, and print the occurrences of each and their total below each result, found in a single pass")
+	fs.BoolVar(&flags.Overlapping, "overlapping", false, "with -count-substr, count overlapping occurrences too (\"aa\" occurs twice in \"aaa\")")
+	fs.IntVar(&flags.CountColumn, "count-column", 0, "also print the number of non-empty values in field `N` (from 1) of each line, split on -field-sep")
+	fs.StringVar(&flags.FieldSep, "field-sep", ",", "with -count-column, the `SEP` between fields (escapes like \\t allowed)")
+	fs.BoolVar(&flags.Unique, "unique", false, "with -count-column, also print the number of distinct values in the field")
+	fs.BoolVar(&flags.DirectRead, "direct-read", false, "read inputs straight into the counting buffer, without bufio (for benchmarking; the counts are the same)")
+	fs.IntVar(&flags.Sample, "sample", 0, "only count every `EVERY`th buffer of each input and scale the line, word and character counts up from it, for quick estimates of huge files (the byte count stays exact)")
+	fs.BoolVar(&flags.Verbose, "verbose", false, "describe the counting modes in effect on stderr before counting")
+	fs.IntVar(&flags.MinWordLen, "min-word-len", 1, "only count words of at least `N` characters")
+	fs.IntVar(&flags.MaxWordCap, "max-word-cap", 0, "truncate the words kept for -top, -unique-words and -stopwords to `N` characters, bounding their memory, and report how many were longer (0 for no limit)")
+	fs.BoolVar(&flags.NoCollapseDelims, "no-collapse-delims", false, "count the whitespace-separated fields of each line as words, two adjacent delimiters enclosing an empty one")
+	fs.StringVar(&flags.Segment, "segment", "", "split words by the rules and a dictionary for `LANG` (zh, ja, ko or th), for text without spaces between words; the counts are language-dependent")
+	fs.StringVar(&flags.SegmentDict, "segment-dict", "", "with -segment, add the words in `FILE`, one per line, to the built-in dictionary")
+	fs.BoolVar(&flags.UnicodeSpace, "unicode-space", false, "decode the input as UTF-8 and split words on Unicode white space, e.g. no-break and ideographic spaces, instead of on single bytes")
+	fs.StringVar(&flags.WordChars, "word-chars", "", "treat letters, digits and the characters in `SET` as word characters; split words on anything else")
+	fs.BoolVar(&flags.StripTags, "strip-tags", false, "remove HTML tags, comments, scripts and styles before counting (bytes still count the raw input)")
+	fs.BoolVar(&flags.NFC, "nfc", false, "normalize the text to Unicode NFC (composed) before counting (bytes still count the raw input)")
+	fs.BoolVar(&flags.NFD, "nfd", false, "normalize the text to Unicode NFD (decomposed) before counting (bytes still count the raw input)")
+	fs.BoolVar(&flags.Recursive, "r", false, "count files in directories recursively")
+	fs.DurationVar(&flags.NewerThan, "newer-than", 0, "with -r, only count files modified less than `DURATION` ago, e.g. 24h")
+	fs.DurationVar(&flags.OlderThan, "older-than", 0, "with -r, only count files modified more than `DURATION` ago")
+	fs.BoolVar(&flags.DirSummary, "dir-summary", false, "with -r, print one line per argument summing up the files in it, instead of one per file")
+	fs.IntVar(&flags.MaxFiles, "max-files", 0, "stop after counting `N` files, e.g. to guard -r against huge trees (0 for no limit)")
+	fs.StringVar(&flags.RelativeTo, "relative-to", "", "print filenames relative to `DIR` (display only)")
+	fs.StringVar(&flags.TrimPrefix, "trim-prefix", "", "strip `PREFIX` from printed filenames (display only)")
+	fs.BoolVar(&flags.Basename, "basename", false, "print only the last element of each filename (display only)")
+	fs.BoolVar(&flags.SafeNames, "safe-names", false, "escape newlines, other control characters and backslashes in printed filenames C-style (\\n, \\t, \\x01, \\\\)")
+	fs.BoolVar(&flags.Merge, "merge", false, "count all inputs as one concatenated stream and print a single line")
+	fs.StringVar(&flags.MergeLabel, "merge-label", "merged", "the `LABEL` printed for the -merge result")
+	fs.StringVar(&flags.ColumnOrder, "column-order", "", "print the columns in the order of `SPEC`, letters from l (lines), w (words), g (graphemes), m (chars) and c or b (bytes), e.g. bwl; others follow as usual")
+	fs.StringVar(&flags.TotalLabel, "total-label", "total", "the `LABEL` printed on the total line, e.g. for reports in other languages")
+	fs.BoolVar(&flags.EOLReport, "eol-report", false, "print a breakdown of \\n, \\r\\n and bare \\r line terminators below each result")
+	fs.Int64Var(&flags.Offset, "offset", 0, "skip the first `N` bytes of each input before counting")
+	fs.Int64Var(&flags.Length, "length", -1, "count at most `M` bytes of each input (-1 for no limit)")
+	// Note: -m counts UTF-8 characters, which differs from -c (bytes)
+	// if the input contains multi-byte characters.
+
+	fs.StringVar(&flags.LineRange, "line-range", "", "count only lines `N:M` (1-based and inclusive; N: or :M leave one end open)")
+	fs.StringVar(&flags.Match, "match", "", "count only the lines matching the regular expression `RE`")
+	fs.StringVar(&flags.SplitOn, "split-on", "", "split standard input at each line consisting of `SENTINEL` and count every segment separately, as (segment N), followed by the total")
+	fs.BoolVar(&flags.ReportEmptyFiles, "report-empty-files", false, "at the end, report on stderr how many of the files counted were empty (zero bytes), e.g. to find failed writes in a tree")
+	fs.BoolVar(&flags.ListEmpty, "list-empty", false, "with -report-empty-files, also list the empty files")
+	fs.Int64Var(&flags.RecordLength, "record-length", 0, "count every `N` bytes as one line, for fixed-width records without delimiters (0 to count newlines)")
+	fs.BoolVar(&flags.PartialRecord, "partial-record", false, "with -record-length, also count a final record shorter than N bytes")
+	fs.BoolVar(&flags.EdgeBlanks, "edge-blanks", false, "print the number of blank lines at the start and at the end of each input below each result, e.g. to lint files in CI")
+	fs.BoolVar(&flags.AdjacentDupes, "adjacent-dupes", false, "print how many lines repeat the previous line and the longest run of identical lines below each result, e.g. to spot a stuck logger")
+	fs.StringVar(&flags.MatchesPerLine, "matches-per-line", "", "print the total number of matches of the regular expression `RE` below each result, counting every match on a line")
+	fs.BoolVar(&flags.OnlyMatching, "only-matching", false, "with -match, count only the matched parts of the lines, like grep -o")
+	fs.BoolVar(&flags.ReportBOM, "report-bom", false, "report the byte order mark each input starts with (utf-8, utf-16le, utf-16be or none) below each result")
+	fs.BoolVar(&flags.SkipBinary, "skip-binary", false, "skip inputs that look binary, reporting them on stderr")
+	fs.Float64Var(&flags.TextThreshold, "text-threshold", defaultTextThreshold, "with -skip-binary, the fraction `RATIO` of text bytes in the first 8 KiB below which an input is binary")
+	fs.StringVar(&flags.PipeThrough, "pipe-through", "", "pipe each input through the shell command `CMD` and count its output instead")
+	fs.BoolVar(&flags.Base64Decode, "base64-decode", false, "decode base64 input and count the decoded content")
+	fs.BoolVar(&flags.Base64URL, "base64-url", false, "with -base64-decode, use the URL-safe alphabet (- and _ for + and /)")
+	fs.BoolVar(&flags.Decompress, "decompress", false, "decompress gzip input and print its compressed size and compression ratio")
+	fs.StringVar(&flags.Encoding, "encoding", encodingUTF8, "decode inputs from `ENC` (utf-8, utf-16le, utf-16be, or auto to detect per file)")
+	fs.BoolVar(&flags.DetectEncoding, "detect-encoding", false, "print the detected encoding of each input instead of counting")
+	fs.BoolVar(&flags.ASCIIOnly, "ascii-only", false, "print whether each input is pure ASCII and the line and column of the first non-ASCII byte instead of counting; exit 1 if any is not")
+	fs.BoolVar(&flags.HasNUL, "has-nul", false, "print whether each input contains a NUL byte and the offset of the first one instead of counting; exit 1 if any does")
+	fs.StringVar(&flags.Annotate, "annotate", "", "prepend a line starting with `PREFIX` and holding the counts to each counted file")
+	fs.BoolVar(&flags.DryRun, "dry-run", false, "with -annotate, print the lines that would be prepended without touching any file")
+	fs.Var(&flags.FDs, "fd", "also count the already-open file descriptor `N` (repeatable)")
+	fs.BoolVar(&flags.Clipboard, "clipboard", false, "also count the text on the system clipboard")
+	fs.StringVar(&flags.Exec, "exec", "", "also run the shell command `CMD` and count its combined standard and error output, showing its exit status below the result")
+	fs.StringVar(&flags.StateFile, "state-file", "", "count only what was appended to each file since the last run, remembering offsets in `PATH`")
+	fs.StringVar(&flags.Checkpoint, "checkpoint", "", "write the files counted and their running total to `FILE` every few seconds and when terminated, removing it once the run completes")
+	fs.BoolVar(&flags.Resume, "resume", false, "with -checkpoint, skip the files a previous, interrupted run already counted and carry on with its total")
+	fs.IntVar(&flags.Jobs, "j", 1, "count up to `N` files concurrently")
+	fs.BoolVar(&flags.Progress, "progress", false, "show files completed and bytes processed on stderr (only when stderr is a terminal)")
+	fs.BoolVar(&flags.Dedup, "dedup", false, "count inputs with identical content only once (the first one), reporting the skipped ones on stderr")
+	fs.StringVar(&flags.Tee, "tee", "", "write the counted input through to `PATH` (the inputs concatenated, in order)")
+	fs.BoolVar(&flags.Server, "server", false, "read commands from stdin, one per line (a file followed by options), and answer each with \"ok N\" and N lines of counts or with \"error MESSAGE\"")
+	fs.Int64Var(&flags.RateLimit, "rate-limit", 0, "read at most `BYTES_PER_SEC` bytes per second from each input (0 for no limit)")
+	fs.BoolVar(&flags.VerifyAgainstWC, "verify-against-wc", false, "count each file and with the system wc too, and report where they differ (hidden)")
+}
+
+// validate checks the option values that flag parsing alone cannot.
+func (f Flags) validate() error {
+	if f.Offset < 0 {
+		return fmt.Errorf("invalid offset: %d", f.Offset)
+	}
+
+	if f.PreviewWidth < 1 {
+		return fmt.Errorf("invalid preview width: %d", f.PreviewWidth)
+	}
+
+	if f.RateLimit < 0 {
+		return fmt.Errorf("invalid rate limit: %d", f.RateLimit)
+	}
+
+	if f.NFC && f.NFD {
+		return errors.New("-nfc and -nfd are mutually exclusive")
+	}
+
+	if f.Prometheus && (f.JSON || f.JSONPretty || f.JSONAllFields || f.JSONErrors) {
+		return errors.New("-prometheus and -json are mutually exclusive")
+	}
+	if f.JSONStreamArray {
+		switch {
+		case f.JSON || f.Prometheus:
+			return errors.New("-json-stream-array cannot be combined with -json or -prometheus")
+		case f.Normalize || f.SortByName:
+			return errors.New("-json-stream-array cannot wait for all results, as -normalize and -sort-by-name need to")
+		}
+	}
+
+	if f.TextThreshold < 0 || f.TextThreshold > 1 {
+		return fmt.Errorf("invalid text threshold: %g (want a ratio from 0 to 1)", f.TextThreshold)
+	}
+	if f.SkipBinary && f.Merge {
+		return errors.New("-skip-binary cannot be combined with -merge")
+	}
+	if f.ReportBOM && f.Merge {
+		return errors.New("-report-bom reports on each input, so it cannot be combined with -merge")
+	}
+
+	if f.ListChars && !f.DistinctChars {
+		return errors.New("-list-chars requires -distinct-chars")
+	}
+
+	if f.ShowLongest < 0 {
+		return fmt.Errorf("invalid number of longest lines: %d", f.ShowLongest)
+	}
+
+	if f.Base64URL && !f.Base64Decode {
+		return errors.New("-base64-url requires -base64-decode")
+	}
+
+	if f.LineRange != "" {
+		if _, _, err := parseLineRange(f.LineRange); err != nil {
+			return err
+		}
+	}
+
+	if f.Match != "" {
+		if _, err := regexp.Compile(f.Match); err != nil {
+			return fmt.Errorf("invalid -match: %w", err)
+		}
+	} else if f.OnlyMatching {
+		return errors.New("-only-matching requires -match")
+	}
+	if f.MatchesPerLine != "" {
+		if _, err := regexp.Compile(f.MatchesPerLine); err != nil {
+			return fmt.Errorf("invalid -matches-per-line: %w", err)
+		}
+	}
+
+	if f.WatchInterval <= 0 {
+		return fmt.Errorf("invalid watch interval: %v", f.WatchInterval)
+	}
+
+	if f.OverLength < 0 {
+		return fmt.Errorf("invalid line length: %d", f.OverLength)
+	}
+
+	if f.TabWidth < 1 {
+		return fmt.Errorf("invalid tab width: %d", f.TabWidth)
+	}
+
+	if f.MinWordLen < 1 {
+		return fmt.Errorf("invalid minimum word length: %d", f.MinWordLen)
+	}
+	if f.SplitOn != "" && f.Merge {
+		return errors.New("-split-on cannot be combined with -merge")
+	}
+	if f.ListEmpty && !f.ReportEmptyFiles {
+		return errors.New("-list-empty requires -report-empty-files")
+	}
+	if f.RecordLength < 0 {
+		return fmt.Errorf("invalid record length: %d", f.RecordLength)
+	}
+	if f.PartialRecord && f.RecordLength == 0 {
+		return errors.New("-partial-record requires -record-length")
+	}
+	if f.RecordLength > 0 {
+		// Records are counted in the bytes as read, not in lines
+		switch {
+		case f.AnyEOL || f.EOLReport:
+			return errors.New("-record-length cannot be combined with -any-eol or -eol-report")
+		case f.Encoding != encodingUTF8 || f.StripTags || f.NFC || f.NFD:
+			return errors.New("-record-length cannot be combined with -encoding, -strip-tags, -nfc or -nfd")
+		case f.LineRange != "" || f.Match != "":
+			return errors.New("-record-length cannot be combined with -line-range or -match")
+		}
+	}
+	if _, err := parseColumnOrder(f.ColumnOrder); err != nil {
+		return err
+	}
+	if f.MaxWordCap < 0 {
+		return fmt.Errorf("invalid word cap: %d", f.MaxWordCap)
+	}
+	if f.MaxWordCap > 0 && f.Stopwords == "" && !f.ShowUniqueWords && f.Top == 0 && f.ApproxTop == 0 {
+		return errors.New("-max-word-cap requires -stopwords, -unique-words, -top or -approx-top")
+	}
+	if f.UnicodeSpace && (f.WordChars != "" || f.Segment != "") {
+		return errors.New("-unicode-space cannot be combined with -word-chars or -segment, which split words on more than white space")
+	}
+	if f.Segment != "" {
+		if err := checkSegmentLang(f.Segment); err != nil {
+			return err
+		}
+		if f.WordChars != "" {
+			return errors.New("-segment and -word-chars are mutually exclusive")
+		}
+	}
+	if f.SegmentDict != "" && f.Segment == "" {
+		return errors.New("-segment-dict requires -segment")
+	}
+
+	// Empty fields have no characters to check or words to collect
+	if f.NoCollapseDelims {
+		switch {
+		case f.WordChars != "" || f.Segment != "" || f.UnicodeSpace:
+			return errors.New("-no-collapse-delims cannot be combined with -word-chars, -segment or -unicode-space")
+		case f.MinWordLen > 1:
+			return errors.New("-no-collapse-delims cannot be combined with -min-word-len")
+		case f.Stopwords != "" || f.ShowUniqueWords || f.Top > 0 || f.ApproxTop > 0:
+			return errors.New("-no-collapse-delims cannot be combined with -stopwords, -unique-words, -top or -approx-top")
+		}
+	}
+
+	if f.Top < 0 {
+		return fmt.Errorf("invalid top count: %d", f.Top)
+	}
+	if f.Sample < 0 {
+		return fmt.Errorf("invalid sample interval: %d", f.Sample)
+	}
+	if f.Sample > 1 && (f.Tee != "" || f.Dedup || f.VerifyAgainstWC) {
+		// The copy, the digest and wc would all see just the sample
+		return errors.New("-sample cannot be combined with -tee, -dedup or -verify-against-wc")
+	}
+	if f.Baseline != "" && (f.Normalize || f.Prometheus) {
+		// Neither prints the detail lines the changes would go on
+		return errors.New("-baseline cannot be combined with -normalize or -prometheus")
+	}
+	if f.MinFrequency < 0 {
+		return fmt.Errorf("invalid minimum frequency: %d", f.MinFrequency)
+	}
+	if f.MinFrequency > 0 && f.Top == 0 {
+		return errors.New("-min-frequency requires -top")
+	}
+
+	if f.MaxFiles < 0 {
+		return fmt.Errorf("invalid file limit: %d", f.MaxFiles)
+	}
+	if f.RunningTotal {
+		switch {
+		case f.JSON || f.JSONStreamArray || f.Prometheus || f.JSONPretty || f.JSONAllFields || f.JSONErrors:
+			return errors.New("-running-total cannot be combined with -json, -json-stream-array or -prometheus")
+		case f.Normalize || f.SortByName || f.BytesTotal || f.Merge:
+			return errors.New("-running-total needs each result printed as it is counted, which -normalize, -sort-by-name, -bytes-total and -merge prevent")
+		}
+	}
+	if f.NewerThan < 0 || f.OlderThan < 0 {
+		return errors.New("-newer-than and -older-than need positive durations")
+	}
+	if (f.NewerThan > 0 || f.OlderThan > 0) && !f.Recursive {
+		return errors.New("-newer-than and -older-than require -r")
+	}
+	if f.DirSummary {
+		switch {
+		case !f.Recursive:
+			return errors.New("-dir-summary requires -r")
+		case f.Merge || f.WatchDir != "" || f.Checkpoint != "" || f.DetectEncoding || f.HasNUL || f.ASCIIOnly:
+			// A resumed -checkpoint run would leave out the files done before
+			return errors.New("-dir-summary cannot be combined with -merge, -watch-dir, -checkpoint, -detect-encoding, -has-nul or -ascii-only")
+		}
+	}
+	if f.NewerThan > 0 && f.OlderThan > 0 && f.NewerThan <= f.OlderThan {
+		return fmt.Errorf("no file can be newer than %v and older than %v", f.NewerThan, f.OlderThan)
+	}
 
-			// Count lines (efficiently check for newline)
-			if char == '\n' {
-				counts.Lines++
-			}
+	if f.ApproxTop < 0 {
+		return fmt.Errorf("invalid top count: %d", f.ApproxTop)
+	}
 
-			// Count words using a state machine
-			// Consider any Unicode space character as a separator.
-			// Cast byte to rune for unicode.IsSpace
-			isSpace := unicode.IsSpace(rune(char))
-			if isSpace {
-				inWord = false
-			} else {
-				// If we were not in a word before, and current char is not space,
-				// it marks the beginning of a new word.
-				if !inWord {
-					counts.Words++
-					inWord = true
-				}
-			}
+	if f.Fields != "" {
+		if _, err := fieldSeparator(f.Fields); err != nil {
+			return err
 		}
+	}
 
-		// Handle read errors
-		if err != nil {
-			if err == io.EOF {
-				break // End of file reached, exit loop normally
-			}
-			// An actual read error occurred
-			return counts, fmt.Errorf("error reading input: %w", err)
+	if f.CountColumn < 0 {
+		return fmt.Errorf("invalid column: %d", f.CountColumn)
+	}
+	if f.CountColumn > 0 {
+		if _, err := fieldSeparator(f.FieldSep); err != nil {
+			return err
 		}
 	}
 
-	return counts, nil
+	switch f.Encoding {
+	case encodingUTF8, encodingUTF16LE, encodingUTF16BE, encodingAuto:
+	default:
+		return fmt.Errorf("unsupported encoding: %s", f.Encoding)
+	}
+	return nil
 }
 
-// formatOutput formats the counts according to the selected flags for printing.
-// It mimics the right-aligned output of standard wc.
-func formatOutput(counts Counts, flags Flags, filename string) string {
-	var parts []string
-	// Use a consistent width for alignment (e.g., 8 characters)
-	const width = 8
-
-	if flags.ShowLines {
-		parts = append(parts, fmt.Sprintf("%*d", width, counts.Lines))
+// applyDefaults resolves the options that imply or override others, and
+// selects the default lines, words and bytes columns if none was chosen.
+func (f *Flags) applyDefaults() {
+	if (f.JSONAllFields || f.JSONPretty || f.JSONErrors) && !f.JSONStreamArray {
+		f.JSON = true
 	}
-	if flags.ShowWords {
-		parts = append(parts, fmt.Sprintf("%*d", width, counts.Words))
+	if f.Widths {
+		f.ShowGraphemes, f.ShowChars, f.ShowBytes = true, true, true
 	}
-	if flags.ShowBytes {
-		parts = append(parts, fmt.Sprintf("%*d", width, counts.Bytes))
+
+	// -bytes-total replaces every other kind of output with a single number
+	if f.BytesTotal {
+		f.JSON, f.JSONAllFields, f.JSONErrors, f.Normalize = false, false, false, false
+		f.JSONStreamArray = false
 	}
 
-	// Add filename if provided
-	if filename != "" {
-		// Add a space separator before the filename
-		parts = append(parts, " "+filename)
+	if f.Match != "" {
+		f.match = regexp.MustCompile(f.Match) // Checked by validate
+	}
+	if f.MatchesPerLine != "" {
+		f.matches = regexp.MustCompile(f.MatchesPerLine)
 	}
 
-	return strings.Join(parts, "")
+	// If no specific count flag is provided, default to showing all three
+	if f.noneSelected() {
+		f.ShowLines = true
+		f.ShowWords = true
+		f.ShowBytes = true
+	}
 }
 
 func main() {
 	// --- 1. Define and Parse Command Line Flags ---
 	var flags Flags
-	flag.BoolVar(&flags.ShowLines, "l", false, "print the newline counts")
-	flag.BoolVar(&flags.ShowWords, "w", false, "print the word counts")
-	flag.BoolVar(&flags.ShowBytes, "c", false, "print the byte counts")
-	// Note: Standard wc also has -m for character count, which is different from -c for bytes
-	// if the input contains multi-byte characters. We are implementing -c (bytes).
+	defineFlags(flag.CommandLine, &flags)
 
 	// Custom usage message
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: %s [-clw] [file ...]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Usage: %s [-Lclmrw] [file ...]\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "Print newline, word, and byte counts for each FILE, and a total line if\n")
 		fmt.Fprintf(os.Stderr, "more than one FILE is specified. With no FILE, or when FILE is -, read standard input.\n\n")
 		fmt.Fprintf(os.Stderr, "Options:\n")
-		flag.PrintDefaults()
+		printDefaults(flag.CommandLine)
 	}
 
 	flag.Parse()
 
-	// If no specific count flag is provided, default to showing all three
-	if !flags.ShowLines && !flags.ShowWords && !flags.ShowBytes {
-		flags.ShowLines = true
-		flags.ShowWords = true
-		flags.ShowBytes = true
+	if err := flags.validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[0], err)
+		os.Exit(1)
+	}
+
+	if flags.Stopwords != "" {
+		stopwords, err := loadStopwords(flags.Stopwords)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s: %v\n", os.Args[0], flags.Stopwords, err)
+			os.Exit(1)
+		}
+		flags.stopwords = stopwords
+	}
+	if flags.Patterns != "" {
+		patterns, err := loadPatterns(flags.Patterns)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s: %v\n", os.Args[0], flags.Patterns, err)
+			os.Exit(1)
+		}
+		flags.patterns = newPatternSet(patterns)
+	}
+	if flags.SegmentDict != "" {
+		dict, err := loadSegmentDict(flags.SegmentDict, flags.Segment)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s: %v\n", os.Args[0], flags.SegmentDict, err)
+			os.Exit(1)
+		}
+		flags.segmentDict = dict
+	}
+	if flags.Baseline != "" {
+		b, err := loadBaseline(flags.Baseline)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s: %v\n", os.Args[0], flags.Baseline, err)
+			os.Exit(1)
+		}
+		flags.baseline = b
+	}
+
+	// -server takes its files, and their options, from stdin until EOF
+	if flags.Server {
+		err := checkServerFlags(flag.CommandLine, false)
+		if err == nil && flag.NArg() > 0 {
+			err = errors.New("-server reads the files to count from standard input")
+		}
+		if err == nil {
+			err = serve(os.Stdin, os.Stdout, flags)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[0], err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	flags.applyDefaults()
+
+	// A development aid, deliberately left out of the usage message
+	if flags.VerifyAgainstWC {
+		os.Exit(verifyAgainstWC(flag.Args(), flags))
+	}
+
+	if flags.Tee != "" {
+		// The copy would be useless if inputs were read concurrently or only in part
+		if flags.Jobs > 1 || flags.DetectEncoding || flags.HasNUL || flags.ASCIIOnly {
+			fmt.Fprintf(os.Stderr, "%s: -tee cannot be combined with -j, -detect-encoding, -has-nul or -ascii-only\n", os.Args[0])
+			os.Exit(1)
+		}
+		tee, err := createTee(flags.Tee)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[0], err)
+			os.Exit(1)
+		}
+		flags.tee = tee
+	}
+
+	if flags.StateFile != "" {
+		state, err := loadState(flags.StateFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[0], err)
+			os.Exit(1)
+		}
+		flags.state = state
+	}
+
+	var checkpoint *checkpointState
+	if flags.Checkpoint != "" {
+		// A checkpoint holds per-file totals, which these do not produce or
+		// cannot be restored from
+		if flags.Merge || flags.Compare || flags.WatchDir != "" || flags.LineStats || flags.ApproxTop > 0 {
+			fmt.Fprintf(os.Stderr, "%s: -checkpoint cannot be combined with -merge, -compare, -watch-dir, -line-stats or -approx-top\n", os.Args[0])
+			os.Exit(1)
+		}
+		var err error
+		if checkpoint, err = openCheckpoint(flags.Checkpoint, flags.Resume); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[0], err)
+			os.Exit(1)
+		}
+		checkpoint.saveOnSignal()
+	} else if flags.Resume {
+		fmt.Fprintf(os.Stderr, "%s: -resume needs -checkpoint\n", os.Args[0])
+		os.Exit(1)
+	}
+
+	// -compare is a mode of its own, with exactly two inputs
+	if flags.Compare {
+		if flag.NArg() != 2 {
+			fmt.Fprintf(os.Stderr, "%s: -compare needs exactly two files\n", os.Args[0])
+			os.Exit(1)
+		}
+		if err := compareFiles(flag.Arg(0), flag.Arg(1), flags); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[0], err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if flags.Verbose {
+		for _, line := range describeModes(flags) {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", os.Args[0], line)
+		}
 	}
 
 	// --- 2. Determine Input Source(s) ---
-	filenames := flag.Args()
+	filenames, err := expandArgs(flag.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[0], err)
+		os.Exit(1)
+	}
 	var totalCounts Counts
 	var filesProcessed int
 	var errorsOccurred bool
+	var nulFound bool        // Has -has-nul found a NUL byte in any input?
+	var nonASCIIFound bool   // Has -ascii-only found a non-ASCII byte?
+	var results []fileResult // Only collected when output waits for the totals (see buffered)
+	var mergeNames []string  // Inputs deferred to a single merged count with -merge
+
+	// With -json-stream-array the array is opened right away; every way
+	// out of the run from here on closes it again
+	var arrayStream *jsonArrayStream
+	if flags.JSONStreamArray {
+		arrayStream = newJSONArrayStream(os.Stdout, flags.JSONPretty)
+	}
+
+	// A panic, a bug in the counting code, still reports what was counted
+	defer func() {
+		if r := recover(); r != nil {
+			arrayStream.close()
+			reportPanic(r, totalCounts, filesProcessed, flags)
+		}
+	}()
+
+	// -resume carries on with the total of the files counted before
+	if checkpoint != nil {
+		totalCounts, filesProcessed = checkpoint.resumedTotal()
+	}
+
+	// recordDone adds a file to the checkpoint, if any, once it is counted
+	recordDone := func(filename string, counts *Counts) {
+		if checkpoint == nil || filename == "-" {
+			return
+		}
+		if err := checkpoint.record(filename, counts); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s: %v\n", os.Args[0], flags.Checkpoint, err)
+			errorsOccurred = true
+		}
+	}
+
+	// addToStream writes the next -json-stream-array element
+	addToStream := func(jc jsonCounts) {
+		if err := arrayStream.add(jc); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[0], err)
+			errorsOccurred = true
+		}
+	}
+
+	// reportError prints a per-file error and remembers to exit non-zero.
+	filesFailed := 0
+	reportError := func(filename string, err error) {
+		errorsOccurred = true
+		filesFailed++
+		if flags.JSONErrors && arrayStream != nil {
+			addToStream(jsonCounts{Filename: displayName(filename, flags), Error: err.Error()})
+			return
+		}
+		if flags.JSONErrors {
+			// Goes into the document next to the successful results
+			results = append(results, fileResult{Filename: displayName(filename, flags), Err: err})
+			return
+		}
+		fmt.Fprintf(os.Stderr, "%s: %s: %v\n", os.Args[0], filename, err)
+	}
+
+	// withheld reports whether -no-total-on-error keeps back the total, which
+	// would silently leave out the inputs that failed
+	withheld := func() bool {
+		return flags.NoTotalOnError && filesFailed > 0
+	}
+
+	// JSON is printed as one document, -normalize needs the grand total
+	// before the first line can be printed, and -sort-by-name needs every
+	// name; all of them collect results until the end.
+	buffered := (flags.JSON || flags.Prometheus || flags.Normalize || flags.SortByName) && !flags.BytesTotal
+
+	// formatLine formats the line printed for one result, and formatTotal
+	// the one for the total, as columns or, with -prose, as a sentence.
+	formatLine := func(counts Counts, filename string) string {
+		if flags.Prose && flags.SafeNames {
+			filename = escapeName(filename)
+		}
+		if flags.Prose {
+			return formatProse(counts, flags, proseSubject(filename))
+		}
+		if flags.KV {
+			return formatKVLine(counts, flags, filename)
+		}
+		return formatOutput(counts, flags, filename)
+	}
+	formatTotal := func() string {
+		if flags.Prose {
+			return formatProse(totalCounts, flags, proseTotalSubject(filesProcessed))
+		}
+		if flags.KV {
+			return formatKVLine(totalCounts, flags, flags.TotalLabel)
+		}
+		return formatOutput(totalCounts, flags, flags.TotalLabel)
+	}
+	// formatRunningTotal formats the -running-total line, labelled so as not
+	// to be mistaken for the final total
+	formatRunningTotal := func() string {
+		if flags.Prose && filesProcessed == 1 {
+			return formatProse(totalCounts, flags, "So far, 1 input has")
+		}
+		if flags.Prose {
+			return formatProse(totalCounts, flags, fmt.Sprintf("So far, %d inputs have", filesProcessed))
+		}
+		if flags.KV {
+			return formatKVLine(totalCounts, flags, runningTotalLabel)
+		}
+		return formatOutput(totalCounts, flags, runningTotalLabel)
+	}
+
+	// printLine prints a result line with its details, preceded by the
+	// -headers row if it is the first.
+	headerPrinted := !flags.Headers || flags.Prose || flags.KV || flags.Prometheus || flags.JSONStreamArray
+	printLine := func(line string, counts Counts) {
+		if !headerPrinted {
+			fmt.Println(formatHeader(flags))
+			headerPrinted = true
+		}
+		printResult(line, counts, flags)
+	}
+
+	// compareTotal compares the final total with the -baseline one, and
+	// printTotal prints it
+	compareTotal := func() {
+		if flags.baseline != nil {
+			totalCounts.Baseline = flags.baseline.delta(flags.TotalLabel, totalCounts, flags)
+		}
+	}
+	printTotal := func() {
+		compareTotal()
+		printLine(formatTotal(), totalCounts)
+	}
+
+	// report records the counts for one input: plain output is printed right
+	// away, buffered output is collected and printed at the end.
+	report := func(counts Counts, filename string) {
+		if flags.baseline != nil {
+			counts.Baseline = flags.baseline.delta(filename, counts, flags)
+		}
+		printed := false
+		if flags.BytesTotal {
+			// Only the grand total is printed, at the very end
+		} else if flags.HideEmpty && allZero(counts, flags) {
+			// Not printed, but still part of the total
+		} else if arrayStream != nil {
+			addToStream(toJSONCounts(counts, flags, filename))
+		} else if buffered {
+			results = append(results, fileResult{Filename: filename, Counts: counts})
+		} else {
+			printLine(formatLine(counts, filename), counts)
+			printed = true
+		}
+
+		// Add to totals. A wrapped-around total would be silently wrong, so
+		// an overflow ends the run.
+		if err := totalCounts.Merge(counts); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[0], err)
+			arrayStream.close()
+			os.Exit(1)
+		}
+		filesProcessed++
+		if flags.RunningTotal && printed {
+			printLine(formatRunningTotal(), totalCounts)
+		}
+	}
+
+	var dedup *dedupSet // Content seen so far with -dedup
+	if flags.Dedup {
+		dedup = newDedupSet()
+	}
+	var emptyFiles []string // Named inputs without a single byte, for -report-empty-files
+	var summary *dirSummary // Counts per argument with -dir-summary
+	if flags.DirSummary {
+		summary = newDirSummary(filenames)
+	}
+
+	// finishFile reports the outcome of counting a single named input.
+	// Errors are printed on stderr without aborting the remaining files.
+	finishFile := func(filename string, counts Counts, err error) {
+		var binary *binaryFileError
+		if errors.As(err, &binary) {
+			// Skipping it was asked for, so it is no error
+			fmt.Fprintf(os.Stderr, "%s: %s: skipped, %v\n", os.Args[0], filename, binary)
+			recordDone(filename, nil)
+			return
+		}
+		if err != nil {
+			reportError(filename, err)
+			return
+		}
+		if dedup != nil {
+			if first, dup := dedup.duplicate(counts.Digest, filename); dup {
+				fmt.Fprintf(os.Stderr, "%s: %s: skipped, same content as %s\n", os.Args[0], filename, first)
+				recordDone(filename, nil)
+				return
+			}
+		}
+		recordDone(filename, &counts)
+		if flags.ReportEmptyFiles && counts.Bytes == 0 && filename != "-" {
+			emptyFiles = append(emptyFiles, displayName(filename, flags))
+		}
+		if summary != nil {
+			// Only reported once every file is counted; an overflow ends
+			// the run, as it does for the total
+			if err := summary.add(filename, counts); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[0], err)
+				arrayStream.close()
+				os.Exit(1)
+			}
+		} else {
+			if filename == "-" {
+				filename = "" // Use empty string to signify stdin for output formatting
+			}
+			report(counts, displayName(filename, flags))
+		}
+
+		// Standard input cannot be rewritten, so it is never annotated
+		if flags.Annotate != "" && filename != "" {
+			if err := annotateFile(filename, counts, flags); err != nil {
+				reportError(filename, err)
+			}
+		}
+	}
+
+	// With -j or -progress, named inputs are queued up and counted by a
+	// worker pool once all of them are known
+	pooled := flags.Jobs > 1 || flags.Progress
+	var poolNames []string
+
+	// processFile counts a single named input, or defers it to -merge or
+	// the worker pool.
+	processFile := func(filename string) {
+		if flags.Merge {
+			mergeNames = append(mergeNames, filename)
+			return
+		}
+		if flags.DetectEncoding {
+			if err := printEncoding(filename, flags); err != nil {
+				reportError(filename, err)
+			}
+			return
+		}
+		if flags.HasNUL {
+			found, err := printNUL(filename, flags)
+			if err != nil {
+				reportError(filename, err)
+			}
+			nulFound = nulFound || found
+			return
+		}
+		if flags.ASCIIOnly {
+			found, err := printASCII(filename, flags)
+			if err != nil {
+				reportError(filename, err)
+			}
+			nonASCIIFound = nonASCIIFound || found
+			return
+		}
+		if pooled {
+			poolNames = append(poolNames, filename)
+			return
+		}
+		counts, err := countFile(filename, flags)
+		finishFile(filename, counts, err)
+	}
 
 	// --- 3. Process Input ---
-	if len(filenames) == 0 {
+	noInputs := len(filenames) == 0 && len(flags.FDs) == 0 && !flags.Clipboard && flags.Exec == ""
+	if flags.WatchDir != "" {
+		// Runs until interrupted; whatever was counted is then summed up as usual
+		if err := watchDir(flags.WatchDir, flags.WatchInterval, flags, finishFile); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s: %v\n", os.Args[0], flags.WatchDir, err)
+			errorsOccurred = true
+		}
+		if filesProcessed > 1 && !buffered && arrayStream == nil && !flags.BytesTotal && !withheld() {
+			printTotal()
+		}
+	} else if noInputs && (flags.DetectEncoding || flags.HasNUL || flags.ASCIIOnly) {
+		processFile("-")
+	} else if noInputs && flags.SplitOn != "" {
+		// Standard input holds several documents, each counted on its own
+		reader, closer, err := openInput("-", flags)
+		if err == nil {
+			err = countSegments(reader, flags, report)
+			closer()
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[0], err)
+			errorsOccurred = true
+		}
+		if filesProcessed > 1 && !buffered && arrayStream == nil && !flags.BytesTotal && !withheld() {
+			printTotal()
+		}
+	} else if noInputs {
 		// Read from standard input
-		counts, err := count(os.Stdin)
+		counts, err := countFile("-", flags)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[0], err)
+			arrayStream.close()
 			os.Exit(1)
 		}
-		fmt.Println(formatOutput(counts, flags, "")) // No filename for stdin
-		filesProcessed = 1                           // Consider stdin as one "file" processed
-		totalCounts = counts                         // For consistency, although total isn't printed for single stdin
+		report(counts, "") // No filename for stdin
 	} else {
-		// Process each file provided as argument
-		for _, filename := range filenames {
-			var currentReader io.Reader
-			var file *os.File
-			var err error
+		// visitArgs hands each file provided as argument to visit. With -r,
+		// directories are walked and every regular file in them is visited.
+		visitArgs := func(visit func(filename string), onError func(filename string, err error)) {
+			// isWalked reports whether filename is a directory to walk with -r
+			isWalked := func(filename string) bool {
+				if !flags.Recursive || filename == "-" {
+					return false
+				}
+				info, err := os.Stat(filename)
+				return err == nil && info.IsDir()
+			}
 
-			// Handle "-" as stdin explicitly
-			if filename == "-" {
-				currentReader = os.Stdin
-				filename = "" // Use empty string to signify stdin for output formatting
-			} else {
-				file, err = os.Open(filename)
-				if err != nil {
-					fmt.Fprintf(os.Stderr, "%s: %s: %v\n", os.Args[0], filename, err)
-					errorsOccurred = true
-					continue // Skip to the next file
+			// visitOne visits a file unless -max-files has been reached,
+			// reporting whether to go on
+			visited := 0
+			root := 0 // The argument being visited, for -dir-summary
+			visitOne := func(filename string) bool {
+				if checkpoint != nil && filename != "-" && checkpoint.done(filename) {
+					return true // Counted by the resumed run already
+				}
+				if flags.MaxFiles > 0 && visited == flags.MaxFiles {
+					return false
 				}
-				// Ensure file is closed even if counting fails partially
-				defer file.Close()
-				currentReader = file
+				visited++
+				if summary != nil {
+					summary.assign(filename, root)
+				}
+				visit(filename)
+				return true
 			}
 
-			counts, err := count(currentReader)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "%s: %s: %v\n", os.Args[0], filename, err)
-				errorsOccurred = true
-				// If it was a file, close it now as the deferred close won't run if we continue
-				if file != nil {
-					file.Close()
+			// -newer-than and -older-than filter the files the walk finds
+			window := newTimeWindow(flags.NewerThan, flags.OlderThan, time.Now())
+
+			for i, filename := range filenames {
+				more := true
+				root = i
+				if isWalked(filename) {
+					if summary != nil {
+						summary.enter(i)
+					}
+					walkDir(filename, func(path string) bool {
+						if window != nil && !window.includes(path, onError) {
+							return true
+						}
+						more = visitOne(path)
+						return more
+					}, onError)
+				} else {
+					more = visitOne(filename)
+				}
+				if !more {
+					fmt.Fprintf(os.Stderr, "%s: stopped after %d files (-max-files)\n", os.Args[0], flags.MaxFiles)
+					return
 				}
-				continue // Skip to the next file
 			}
+		}
+
+		// When the pool only counts, the walk can feed it directly, so that
+		// counting starts before the walk is over
+		streamed := pooled && flags.Recursive && !flags.Merge && !flags.DetectEncoding && !flags.HasNUL && !flags.ASCIIOnly
+		if streamed {
+			countParallel(visitArgs, 0, flags, finishFile)
+		} else {
+			visitArgs(processFile, reportError)
+		}
+
+		if pooled && !streamed {
+			countParallel(func(emit func(string), fail func(string, error)) {
+				for _, filename := range poolNames {
+					emit(filename)
+				}
+			}, len(poolNames), flags, finishFile)
+		}
 
-			// Close the file manually if it was opened (deferred close handles the happy path)
-			// No need to explicitly close here if using defer correctly.
-			// if file != nil {
-			//     file.Close() // Already deferred
-			// }
+		// With -dir-summary the files were only summed up: print the sums
+		if summary != nil {
+			summary.each(func(counts Counts, root string) {
+				if root == "-" {
+					root = ""
+				}
+				report(counts, displayName(root, flags))
+			})
+		}
 
-			// Print counts for the current file
-			fmt.Println(formatOutput(counts, flags, filename))
+		// Inherited file descriptors come after the named files
+		for _, fd := range flags.FDs {
+			counts, err := countFD(fd, flags)
+			if err != nil {
+				reportError(fdName(fd), err)
+				continue
+			}
+			report(counts, fdName(fd))
+		}
+		if flags.Clipboard {
+			counts, err := countClipboard(flags)
+			if err != nil {
+				reportError(clipboardName, err)
+			} else {
+				report(counts, clipboardName)
+			}
+		}
+		if flags.Exec != "" {
+			counts, err := countExec(flags.Exec, flags)
+			if err != nil {
+				reportError(flags.Exec, err)
+			} else {
+				report(counts, flags.Exec)
+			}
+		}
 
-			// Add to totals
-			totalCounts.Lines += counts.Lines
-			totalCounts.Words += counts.Words
-			totalCounts.Bytes += counts.Bytes
-			filesProcessed++
+		// With -merge nothing has been counted yet: do it now, in one pass
+		if flags.Merge {
+			counts, err := countMerged(mergeNames, flags, reportError)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[0], err)
+				arrayStream.close()
+				os.Exit(1)
+			}
+			report(counts, flags.MergeLabel)
 		}
 
 		// --- 4. Print Total (if multiple files were processed) ---
-		if filesProcessed > 1 {
-			fmt.Println(formatOutput(totalCounts, flags, "total"))
+		if filesProcessed > 1 && !buffered && arrayStream == nil && !flags.BytesTotal && !withheld() {
+			printTotal()
+		}
+	}
+
+	// --- 5. Print Buffered Results ---
+	if flags.SortByName {
+		sortResults(results)
+	}
+
+	switch {
+	case flags.JSON:
+		// JSON output is a single document, so it is only written once everything is counted
+		compareTotal()
+		out, err := formatJSON(results, totalCounts, filesProcessed, flags)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[0], err)
+			os.Exit(1)
+		}
+		fmt.Println(out)
+	case arrayStream != nil:
+		// The results are out already; the total closes the array
+		if filesProcessed > 1 && !withheld() {
+			compareTotal()
+			addToStream(toJSONCounts(totalCounts, flags, flags.TotalLabel))
+		}
+		arrayStream.close()
+	case flags.Prometheus:
+		// Every metric groups the samples of all inputs
+		fmt.Println(formatPrometheus(results, totalCounts, filesProcessed, flags))
+	case flags.Normalize:
+		// With -normalize every line is printed now that the grand total is known
+		for _, r := range results {
+			printLine(formatNormalized(r.Counts, totalCounts, flags, r.Filename), r.Counts)
+		}
+		if filesProcessed > 1 && !withheld() {
+			printLine(formatNormalized(totalCounts, totalCounts, flags, flags.TotalLabel), totalCounts)
+		}
+	case buffered:
+		for _, r := range results {
+			printLine(formatLine(r.Counts, r.Filename), r.Counts)
+		}
+		if filesProcessed > 1 && !withheld() {
+			printTotal()
+		}
+	}
+
+	if dedup != nil {
+		fmt.Fprintf(os.Stderr, "%s: %d duplicates skipped\n", os.Args[0], dedup.skipped)
+	}
+	if flags.baseline != nil {
+		for _, name := range flags.baseline.removed(flags.TotalLabel) {
+			fmt.Fprintf(os.Stderr, "%s: %s: removed since baseline\n", os.Args[0], name)
+		}
+	}
+	if flags.ReportEmptyFiles {
+		fmt.Fprintf(os.Stderr, "%s: %d empty files\n", os.Args[0], len(emptyFiles))
+		if flags.ListEmpty {
+			for _, name := range emptyFiles {
+				fmt.Fprintf(os.Stderr, "%s: empty: %s\n", os.Args[0], name)
+			}
+		}
+	}
+
+	// A complete run needs no checkpoint; one with errors keeps it, so that
+	// a resumed run retries just the files that failed
+	if checkpoint != nil {
+		err := checkpoint.remove()
+		if errorsOccurred {
+			err = checkpoint.save()
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s: %v\n", os.Args[0], flags.Checkpoint, err)
+			errorsOccurred = true
+		}
+	}
+
+	if flags.tee != nil {
+		if err := flags.tee.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[0], err)
+			errorsOccurred = true
 		}
 	}
 
-	// Exit with non-zero status if any errors occurred during file processing
-	if errorsOccurred {
+	// Remember how far each file was counted, for the next run
+	if flags.state != nil {
+		if err := flags.state.save(); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s: %v\n", os.Args[0], flags.StateFile, err)
+			errorsOccurred = true
+		}
+	}
+
+	// The averages follow the total, however it was printed
+	if flags.Averages && filesProcessed > 0 && !flags.JSON && !flags.JSONStreamArray && !flags.Prometheus && !flags.BytesTotal && !withheld() {
+		fmt.Println(formatAverage(totalCounts, filesProcessed, flags))
+	}
+
+	// A bare number, meant for capturing in shell scripts
+	if flags.BytesTotal && !withheld() {
+		fmt.Println(totalCounts.Bytes)
+	}
+	if withheld() && !flags.JSON {
+		fmt.Fprintf(os.Stderr, "%s: no total printed, %d inputs could not be counted (-no-total-on-error)\n", os.Args[0], filesFailed)
+	}
+
+	// Exit with non-zero status if any errors occurred during file processing,
+	// or if -has-nul, -ascii-only or -over-length found what they look for
+	if errorsOccurred || nulFound || nonASCIIFound || totalCounts.OverLength > 0 {
 		os.Exit(1)
 	}
 }
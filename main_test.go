@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// benchmarkFile writes benchmarkText to a temporary file and returns its
+// name.
+func benchmarkFile(tb testing.TB) string {
+	name := filepath.Join(tb.TempDir(), "input.txt")
+	if err := os.WriteFile(name, benchmarkText(), 0o644); err != nil {
+		tb.Fatal(err)
+	}
+	return name
+}
+
+// countNamed counts the file name with flags.
+func countNamed(tb testing.TB, name string, flags Flags) Counts {
+	file, err := os.Open(name)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer file.Close()
+	counts, err := count(file, flags)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return counts
+}
+
+func TestDirectRead(t *testing.T) {
+	name := benchmarkFile(t)
+	flags := Flags{ShowLines: true, ShowWords: true, ShowBytes: true}
+	buffered := countNamed(t, name, flags)
+	flags.DirectRead = true
+	direct := countNamed(t, name, flags)
+	if direct.Lines != buffered.Lines || direct.Words != buffered.Words || direct.Bytes != buffered.Bytes {
+		t.Errorf("-direct-read counted %d %d %d, without it %d %d %d",
+			direct.Lines, direct.Words, direct.Bytes, buffered.Lines, buffered.Words, buffered.Bytes)
+	}
+}
+
+func benchmarkRead(b *testing.B, direct bool) {
+	name := benchmarkFile(b)
+	flags := Flags{ShowLines: true, ShowWords: true, ShowBytes: true, DirectRead: direct}
+	b.SetBytes(int64(len(benchmarkText())))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		countNamed(b, name, flags)
+	}
+}
+
+// BenchmarkBufferedRead and BenchmarkDirectRead count the same file with
+// and without -direct-read, to measure what the bufio.Reader layer costs.
+func BenchmarkBufferedRead(b *testing.B) { benchmarkRead(b, false) }
+func BenchmarkDirectRead(b *testing.B)   { benchmarkRead(b, true) }
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"regexp"
+)
+
+// matchFilter is a reader that passes on only the lines matching the -match
+// regexp, terminators included, so that every count describes the matching
+// lines alone.
+//
+// With -only-matching it passes on just the matched parts of those lines
+// instead, like grep -o: the matches of a line are joined by a blank, which
+// keeps them from running together into one word, and followed by a '\n',
+// so that each matching line still counts as a line. inserted tallies these
+// added bytes for the caller to take back out of the byte and character
+// counts.
+type matchFilter struct {
+	src  *bufio.Reader
+	re   *regexp.Regexp
+	only bool
+
+	inserted int64
+	line     []byte // The line being read, up to and including its '\n'
+	out      []byte // Text not yet returned to the caller
+	err      error
+}
+
+func newMatchFilter(src io.Reader, re *regexp.Regexp, only bool) *matchFilter {
+	return &matchFilter{src: bufio.NewReaderSize(src, bufferSize), re: re, only: only}
+}
+
+func (m *matchFilter) Read(p []byte) (int, error) {
+	// Keep reading while lines don't match, so that a 0, nil result never
+	// reaches the caller
+	for len(m.out) == 0 && m.err == nil {
+		m.readLine()
+	}
+
+	n := copy(p, m.out)
+	m.out = m.out[n:]
+	if len(m.out) > 0 {
+		return n, nil
+	}
+	m.out = m.out[:0]
+	return n, m.err
+}
+
+// readLine reads the next line from src and appends whatever is passed on
+// from it to out.
+func (m *matchFilter) readLine() {
+	m.line = m.line[:0]
+	for {
+		chunk, err := m.src.ReadSlice('\n')
+		m.line = append(m.line, chunk...)
+		if err == bufio.ErrBufferFull {
+			continue // A line longer than the buffer
+		}
+		if err != nil {
+			m.err = err
+		}
+		break
+	}
+	if len(m.line) == 0 {
+		return
+	}
+
+	// Match against the text of the line alone, so that $ works for CRLF
+	// lines too
+	text := bytes.TrimSuffix(m.line, []byte("\n"))
+	text = bytes.TrimSuffix(text, []byte("\r"))
+	if !m.only {
+		if m.re.Match(text) {
+			m.out = append(m.out, m.line...)
+		}
+		return
+	}
+
+	matches := m.re.FindAllIndex(text, -1)
+	if len(matches) == 0 {
+		return
+	}
+	for i, loc := range matches {
+		if i > 0 {
+			m.out = append(m.out, ' ')
+			m.inserted++
+		}
+		m.out = append(m.out, text[loc[0]:loc[1]]...)
+	}
+	m.out = append(m.out, '\n')
+	m.inserted++
+}
+
+// matchTally counts every match of the -matches-per-line regexp, several
+// on one line included. Matching is done a line at a time, so a match
+// never spans a line break; a line split across chunks is kept until its
+// end arrives.
+type matchTally struct {
+	re    *regexp.Regexp
+	line  []byte // Start of the current line, seen in earlier chunks
+	count int64
+}
+
+func (m *matchTally) feed(buf []byte) {
+	for len(buf) > 0 {
+		i := bytes.IndexByte(buf, '\n')
+		if i < 0 {
+			m.line = append(m.line, buf...)
+			return
+		}
+		if len(m.line) > 0 {
+			m.line = append(m.line, buf[:i]...)
+			m.endLine(m.line)
+			m.line = m.line[:0]
+		} else {
+			m.endLine(buf[:i]) // The whole line is in this chunk
+		}
+		buf = buf[i+1:]
+	}
+}
+
+// endLine counts the matches on one line, given without its '\n'.
+func (m *matchTally) endLine(line []byte) {
+	line = bytes.TrimSuffix(line, []byte("\r"))
+	m.count += int64(len(m.re.FindAllIndex(line, -1)))
+}
+
+// finish counts a final line lacking a terminator.
+func (m *matchTally) finish() {
+	if len(m.line) > 0 {
+		m.endLine(m.line)
+		m.line = m.line[:0]
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"time"
+)
+
+// timeWindow selects the files -newer-than and -older-than let through, by
+// their modification time. A zero bound is no bound.
+type timeWindow struct {
+	after  time.Time // Modified after this, for -newer-than
+	before time.Time // Modified before this, for -older-than
+}
+
+// newTimeWindow returns the window for the two durations, counted back from
+// now, or nil if neither is set.
+func newTimeWindow(newerThan, olderThan time.Duration, now time.Time) *timeWindow {
+	if newerThan == 0 && olderThan == 0 {
+		return nil
+	}
+	w := &timeWindow{}
+	if newerThan > 0 {
+		w.after = now.Add(-newerThan)
+	}
+	if olderThan > 0 {
+		w.before = now.Add(-olderThan)
+	}
+	return w
+}
+
+// contains reports whether a file modified at modTime is in the window.
+func (w *timeWindow) contains(modTime time.Time) bool {
+	if !w.after.IsZero() && !modTime.After(w.after) {
+		return false
+	}
+	if !w.before.IsZero() && !modTime.Before(w.before) {
+		return false
+	}
+	return true
+}
+
+// includes stats path and reports whether it is in the window. A file that
+// cannot be stat'ed is not, and its error goes to onError.
+func (w *timeWindow) includes(path string, onError func(path string, err error)) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		onError(path, err)
+		return false
+	}
+	return w.contains(info.ModTime())
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"io"
+	"sort"
+	"sync"
+	"unicode/utf8"
+)
+
+//go:generate go run gen_normtables.go
+
+// cccRange, decomposition and composition are the entries of the tables in
+// normtables.go, which gen_normtables.go generates from the Unicode
+// character database.
+type cccRange struct {
+	lo, hi rune
+	class  uint8
+}
+
+type decomposition struct {
+	r    rune
+	full string
+}
+
+type composition struct {
+	first, second, composite rune
+}
+
+// The Hangul syllables decompose and compose algorithmically rather than
+// through the tables (see the Unicode standard, section 3.12).
+const (
+	hangulSBase  = 0xAC00
+	hangulLBase  = 0x1100
+	hangulVBase  = 0x1161
+	hangulTBase  = 0x11A7
+	hangulLCount = 19
+	hangulVCount = 21
+	hangulTCount = 28
+	hangulNCount = hangulVCount * hangulTCount
+	hangulSCount = hangulLCount * hangulNCount
+)
+
+// normMaps holds the lookup maps built from the tables. Only -nfc and -nfd
+// need them, so they are built on first use rather than at startup.
+var normMaps struct {
+	once         sync.Once
+	decompose    map[rune]string
+	compose      map[[2]rune]rune
+	secondStarts map[rune]bool // Starters that can combine with a preceding starter
+}
+
+func loadNormMaps() {
+	normMaps.once.Do(func() {
+		normMaps.decompose = make(map[rune]string, len(decompositions))
+		for _, d := range decompositions {
+			normMaps.decompose[d.r] = d.full
+		}
+		normMaps.compose = make(map[[2]rune]rune, len(compositions))
+		normMaps.secondStarts = make(map[rune]bool)
+		for _, c := range compositions {
+			normMaps.compose[[2]rune{c.first, c.second}] = c.composite
+			if combiningClass(c.second) == 0 {
+				normMaps.secondStarts[c.second] = true
+			}
+		}
+	})
+}
+
+// combiningClass returns the canonical combining class of r; 0 means r is
+// a starter. Invalid bytes (negative runes, see normReader) are starters.
+func combiningClass(r rune) uint8 {
+	if r < 0x300 {
+		return 0
+	}
+	i := sort.Search(len(cccRanges), func(i int) bool { return cccRanges[i].hi >= r })
+	if i < len(cccRanges) && cccRanges[i].lo <= r {
+		return cccRanges[i].class
+	}
+	return 0
+}
+
+// isSecondStarter reports whether r is a starter that canonical composition
+// may combine with the starter right before it, such as a Hangul vowel.
+func isSecondStarter(r rune) bool {
+	if r >= hangulVBase && r < hangulVBase+hangulVCount ||
+		r > hangulTBase && r < hangulTBase+hangulTCount {
+		return true
+	}
+	return normMaps.secondStarts[r]
+}
+
+// appendDecomposed appends the full canonical decomposition of r to dst.
+func appendDecomposed(dst []rune, r rune) []rune {
+	if r >= hangulSBase && r < hangulSBase+hangulSCount {
+		s := r - hangulSBase
+		dst = append(dst, hangulLBase+s/hangulNCount, hangulVBase+(s%hangulNCount)/hangulTCount)
+		if t := s % hangulTCount; t != 0 {
+			dst = append(dst, hangulTBase+t)
+		}
+		return dst
+	}
+	if full, ok := normMaps.decompose[r]; ok {
+		for _, d := range full {
+			dst = append(dst, d)
+		}
+		return dst
+	}
+	return append(dst, r)
+}
+
+// composePair returns the primary composite of first and second, if any.
+func composePair(first, second rune) (rune, bool) {
+	switch {
+	case second < 0x300:
+		return 0, false // Nothing composes with ASCII or Latin-1 text
+
+	case first >= hangulLBase && first < hangulLBase+hangulLCount &&
+		second >= hangulVBase && second < hangulVBase+hangulVCount:
+		return hangulSBase + ((first-hangulLBase)*hangulVCount+second-hangulVBase)*hangulTCount, true
+	case first >= hangulSBase && first < hangulSBase+hangulSCount && (first-hangulSBase)%hangulTCount == 0 &&
+		second > hangulTBase && second < hangulTBase+hangulTCount:
+		return first + second - hangulTBase, true
+	}
+	composite, ok := normMaps.compose[[2]rune{first, second}]
+	return composite, ok
+}
+
+// reorder puts every run of non-starters in seq into canonical order: a
+// stable sort by combining class.
+func reorder(seq []rune) {
+	for i := 1; i < len(seq); i++ {
+		cc := combiningClass(seq[i])
+		if cc == 0 {
+			continue
+		}
+		for j := i; j > 0; j-- {
+			prev := combiningClass(seq[j-1])
+			if prev == 0 || prev <= cc {
+				break
+			}
+			seq[j-1], seq[j] = seq[j], seq[j-1]
+		}
+	}
+}
+
+// composeSeq applies canonical composition to the decomposed, reordered
+// seq in place and returns the result.
+func composeSeq(seq []rune) []rune {
+	out := seq[:0]
+	starter := -1 // Index in out of the last starter
+	for _, r := range seq {
+		cc := combiningClass(r)
+		if starter >= 0 {
+			// r may combine with the starter unless something in between,
+			// being a starter or of at least r's class, blocks it
+			last := len(out) - 1
+			if last == starter || cc != 0 && combiningClass(out[last]) < cc {
+				if composite, ok := composePair(out[starter], r); ok {
+					out[starter] = composite
+					continue
+				}
+			}
+		}
+		if cc == 0 {
+			starter = len(out)
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+// maxPendingRunes caps how much decomposed text normReader holds back while
+// waiting for a safe place to normalize up to. Only pathological input, such
+// as endless combining marks, gets there; it is then normalized in pieces.
+const maxPendingRunes = bufferSize
+
+// normReader normalizes UTF-8 text to NFC or NFD as it is read through it
+// (see -nfc and -nfd). Text is decomposed as it arrives and held back until
+// a starter that nothing before it can interact with, up to which it is then
+// reordered, composed for NFC, and encoded again. Bytes that are not valid
+// UTF-8 pass through unchanged; they are kept among the runes as negative
+// values, -1-b for byte b.
+type normReader struct {
+	src     io.Reader
+	compose bool
+
+	raw     []byte // Reusable buffer for reads from src
+	carry   []byte // Bytes of a character split across reads
+	pending []rune // Decomposed text not yet normalized
+	out     []byte // Normalized text not yet returned to the caller
+	err     error
+}
+
+func newNormReader(src io.Reader, compose bool) *normReader {
+	loadNormMaps()
+	return &normReader{src: src, compose: compose, raw: make([]byte, bufferSize)}
+}
+
+func (n *normReader) Read(p []byte) (int, error) {
+	for len(n.out) == 0 && n.err == nil {
+		k, err := n.src.Read(n.raw)
+		n.decode(n.raw[:k])
+		if err != nil {
+			for _, b := range n.carry {
+				n.pending = append(n.pending, -1-rune(b)) // Never completed
+			}
+			n.carry = n.carry[:0]
+			n.flush(len(n.pending))
+			n.err = err
+		} else {
+			n.flush(n.boundary())
+		}
+	}
+
+	k := copy(p, n.out)
+	n.out = n.out[k:]
+	if len(n.out) == 0 && n.err != nil {
+		return k, n.err
+	}
+	return k, nil
+}
+
+// decode decomposes data onto n.pending, carrying an incomplete character
+// at its end over to the next call.
+func (n *normReader) decode(data []byte) {
+	if len(n.carry) > 0 {
+		data = append(n.carry, data...)
+		n.carry = nil
+	}
+	for i := 0; i < len(data); {
+		b := data[i]
+		if b < utf8.RuneSelf {
+			n.pending = append(n.pending, rune(b))
+			i++
+			continue
+		}
+		if !utf8.FullRune(data[i:]) {
+			n.carry = append(n.carry, data[i:]...)
+			return
+		}
+		r, size := utf8.DecodeRune(data[i:])
+		if r == utf8.RuneError && size == 1 {
+			n.pending = append(n.pending, -1-rune(b))
+		} else {
+			n.pending = appendDecomposed(n.pending, r)
+		}
+		i += size
+	}
+}
+
+// boundary returns how much of n.pending is safe to normalize: everything
+// before the last starter that cannot combine with what precedes it.
+func (n *normReader) boundary() int {
+	for i := len(n.pending) - 1; i > 0; i-- {
+		r := n.pending[i]
+		if combiningClass(r) == 0 && !isSecondStarter(r) {
+			return i
+		}
+	}
+	if len(n.pending) > maxPendingRunes {
+		return len(n.pending)
+	}
+	return 0
+}
+
+// flush normalizes the first k runes of n.pending onto n.out.
+func (n *normReader) flush(k int) {
+	seq := n.pending[:k]
+	reorder(seq)
+	if n.compose {
+		seq = composeSeq(seq)
+	}
+	var buf [utf8.UTFMax]byte
+	for _, r := range seq {
+		if r < 0 {
+			n.out = append(n.out, byte(-1-r))
+			continue
+		}
+		size := utf8.EncodeRune(buf[:], r)
+		n.out = append(n.out, buf[:size]...)
+	}
+	n.pending = append(n.pending[:0], n.pending[k:]...)
+}
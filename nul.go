@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// findNUL returns the offset of the first NUL byte read from reader, or -1
+// if there is none. Unlike counting, this only needs bytes.IndexByte per
+// chunk, so it runs at close to memory speed.
+func findNUL(reader io.Reader) (int64, error) {
+	buf := make([]byte, bufferSize)
+	var offset int64
+	for {
+		n, err := reader.Read(buf)
+		if i := bytes.IndexByte(buf[:n], 0); i >= 0 {
+			return offset + int64(i), nil
+		}
+		offset += int64(n)
+		if err == io.EOF {
+			return -1, nil
+		}
+		if err != nil {
+			return -1, err
+		}
+	}
+}
+
+// printNUL reports whether the named input contains a NUL byte and, if so,
+// the offset of the first one within the input (so -offset is taken into
+// account). found tells the caller to exit non-zero.
+func printNUL(filename string, flags Flags) (found bool, err error) {
+	reader, closer, err := openInput(filename, flags)
+	if err != nil {
+		return false, err
+	}
+	defer closer()
+
+	offset, err := findNUL(reader)
+	if err != nil {
+		return false, err
+	}
+
+	result := "no nul"
+	if offset >= 0 {
+		result = fmt.Sprintf("nul at %d", flags.Offset+offset)
+	}
+	if filename == "-" {
+		fmt.Println(result)
+	} else {
+		fmt.Printf("%s %s\n", result, displayName(filename, flags))
+	}
+	return offset >= 0, nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime/debug"
+)
+
+// exitPanic is the exit status after an internal error (a panic), set apart
+// from the 1 of ordinary failures and the 2 of usage errors so that batch
+// jobs can tell a bug from a bad input.
+const exitPanic = 3
+
+// countPanic carries a panic out of a -j worker to the main goroutine,
+// together with the stack of the worker where it happened.
+type countPanic struct {
+	filename string
+	value    interface{}
+	stack    []byte
+}
+
+// recoverCount converts a panic while counting filename into a countPanic
+// stored in *p. It must be deferred.
+func recoverCount(filename string, p **countPanic) {
+	if r := recover(); r != nil {
+		*p = &countPanic{filename: filename, value: r, stack: debug.Stack()}
+	}
+}
+
+// reportPanic logs a panic that reached main, along with the total of the
+// inputs counted before it, to stderr and exits with exitPanic. The counts
+// are what was accumulated so far, so they leave out the input being
+// counted when it happened.
+func reportPanic(value interface{}, total Counts, inputs int, flags Flags) {
+	stack := debug.Stack()
+	if p, ok := value.(*countPanic); ok {
+		fmt.Fprintf(os.Stderr, "%s: %s: internal error: %v\n", os.Args[0], p.filename, p.value)
+		stack = p.stack
+	} else {
+		fmt.Fprintf(os.Stderr, "%s: internal error: %v\n", os.Args[0], value)
+	}
+	os.Stderr.Write(stack)
+	fmt.Fprintf(os.Stderr, "%s: partial total of the %d inputs counted before the error:\n", os.Args[0], inputs)
+	if flags.Headers {
+		fmt.Fprintln(os.Stderr, formatHeader(flags))
+	}
+	fmt.Fprintln(os.Stderr, formatOutput(total, flags, flags.TotalLabel))
+	os.Exit(exitPanic)
+}
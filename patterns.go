@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// loadPatterns reads the -patterns file: one byte string per line, taken
+// literally apart from the line terminator. Empty lines are skipped, and a
+// pattern given twice is only counted once.
+func loadPatterns(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var patterns []string
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		pattern := strings.TrimSuffix(scanner.Text(), "\r")
+		if pattern != "" && !seen[pattern] {
+			seen[pattern] = true
+			patterns = append(patterns, pattern)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(patterns) == 0 {
+		return nil, fmt.Errorf("no patterns in %s", path)
+	}
+	return patterns, nil
+}
+
+// patternSet is an Aho-Corasick automaton finding every occurrence of any
+// of a set of byte strings in a single pass, whatever their number. The
+// trie of the patterns is turned into a complete DFA, one transition per
+// state and byte, so that matching costs one table lookup per input byte;
+// outputs lists the patterns ending at each state, those found through
+// its failure links included. All occurrences are counted, overlapping
+// ones and patterns within other patterns too.
+type patternSet struct {
+	patterns []string
+	next     [][256]int32
+	outputs  [][]int32
+}
+
+// newPatternSet builds the automaton for patterns, which must not be empty.
+func newPatternSet(patterns []string) *patternSet {
+	s := &patternSet{patterns: patterns, next: make([][256]int32, 1), outputs: make([][]int32, 1)}
+
+	// The trie, with 0 standing for "no transition yet" (the root is never
+	// the target of one)
+	for i, pattern := range patterns {
+		state := int32(0)
+		for j := 0; j < len(pattern); j++ {
+			b := pattern[j]
+			if s.next[state][b] == 0 {
+				s.next = append(s.next, [256]int32{})
+				s.outputs = append(s.outputs, nil)
+				s.next[state][b] = int32(len(s.next) - 1)
+			}
+			state = s.next[state][b]
+		}
+		s.outputs[state] = append(s.outputs[state], int32(i))
+	}
+
+	// Breadth first, every state's failure target is already complete
+	// when the state is reached, so its missing transitions can be
+	// copied from there
+	fail := make([]int32, len(s.next))
+	var queue []int32
+	for b := 0; b < 256; b++ {
+		if child := s.next[0][b]; child != 0 {
+			queue = append(queue, child)
+		}
+	}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		s.outputs[state] = append(s.outputs[state], s.outputs[fail[state]]...)
+		for b := 0; b < 256; b++ {
+			child := s.next[state][b]
+			if child == 0 {
+				s.next[state][b] = s.next[fail[state]][b]
+				continue
+			}
+			fail[child] = s.next[fail[state]][b]
+			queue = append(queue, child)
+		}
+	}
+	return s
+}
+
+// patternCounter counts the occurrences of each pattern of a set. The
+// automaton's state carries over from one chunk to the next, so an
+// occurrence straddling two chunks is found like any other.
+type patternCounter struct {
+	set    *patternSet
+	state  int32
+	counts []int64
+}
+
+func newPatternCounter(set *patternSet) *patternCounter {
+	return &patternCounter{set: set, counts: make([]int64, len(set.patterns))}
+}
+
+func (p *patternCounter) feed(buf []byte) {
+	next, outputs, state := p.set.next, p.set.outputs, p.state
+	for _, b := range buf {
+		state = next[state][b]
+		for _, i := range outputs[state] {
+			p.counts[i]++
+		}
+	}
+	p.state = state
+}
+
+// patternHits returns the sum of the counts of all patterns.
+func patternHits(counts []int64) int64 {
+	var hits int64
+	for _, n := range counts {
+		hits += n
+	}
+	return hits
+}
+
+// formatPatterns lists the -patterns counts below a result, in the order
+// of the patterns file, after the total number of hits.
+func formatPatterns(set *patternSet, counts []int64, flags Flags) []string {
+	lines := []string{"    patterns: " + formatCount(patternHits(counts), flags) + " hits"}
+	for i, pattern := range set.patterns {
+		var n int64
+		if i < len(counts) {
+			n = counts[i]
+		}
+		lines = append(lines, fmt.Sprintf("    %*d %q", columnWidth, n, pattern))
+	}
+	return lines
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+)
+
+// pipedReader yields the standard output of an external command, such as
+// a -pipe-through command reading an input on its standard input. The
+// command's exit status is only known once its output ends, so a failure
+// surfaces as the error of the final Read.
+type pipedReader struct {
+	what   string // Describes the command in errors
+	cmd    *exec.Cmd
+	stdout io.ReadCloser
+	waited bool
+}
+
+// pipeThrough starts command with the system shell (see execCommand),
+// feeding it reader.
+func pipeThrough(reader io.Reader, command string) (*pipedReader, error) {
+	cmd := execCommand(command)
+	cmd.Stdin = reader
+	return startPiped(cmd, fmt.Sprintf("-pipe-through command %q", command))
+}
+
+// startPiped starts cmd and returns a reader of its output. Its error output
+// is passed through to ours, so that its own messages are not lost.
+func startPiped(cmd *exec.Cmd, what string) (*pipedReader, error) {
+	cmd.Stderr = os.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("error starting %s: %w", what, err)
+	}
+	return &pipedReader{what: what, cmd: cmd, stdout: stdout}, nil
+}
+
+func (p *pipedReader) Read(buf []byte) (int, error) {
+	n, err := p.stdout.Read(buf)
+	if err == io.EOF && !p.waited {
+		p.waited = true
+		if werr := p.cmd.Wait(); werr != nil {
+			return n, fmt.Errorf("%s failed: %w", p.what, werr)
+		}
+	}
+	return n, err
+}
+
+// Close stops the command if its output was not read to the end, e.g.
+// because counting failed, and waits for it to exit.
+func (p *pipedReader) Close() error {
+	if p.waited {
+		return nil
+	}
+	p.waited = true
+	p.cmd.Process.Kill()
+	return p.cmd.Wait()
+}
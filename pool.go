@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// poolItem is one input passing through the worker pool: its position in
+// the order inputs were produced, and the outcome of counting it. An input
+// the producer already failed on (e.g. an unreadable directory) arrives
+// with err set and is passed through without counting.
+type poolItem struct {
+	index    int
+	filename string
+	counts   Counts
+	err      error
+	panic    *countPanic // Set instead if counting panicked
+}
+
+// windowPerWorker bounds how many inputs each worker may be ahead of the
+// output, which keeps memory bounded however fast the producer finds inputs.
+const windowPerWorker = 4
+
+// countParallel counts the inputs named by produce with up to flags.Jobs
+// workers. produce runs in its own goroutine, handing each input to emit
+// (or a failure to fail) as it finds it, so a directory walk and counting
+// overlap. Results are handed to finish in the order they were produced, as
+// soon as they and all the results before them are available. total is the
+// number of inputs, if known in advance, for the progress line.
+//
+// At most windowPerWorker inputs per worker are in flight or waiting to be
+// printed at any time: emit blocks when that many are outstanding, so a
+// producer that outpaces counting is held back rather than piling up work.
+func countParallel(produce func(emit func(filename string), fail func(filename string, err error)), total int, flags Flags, finish func(filename string, counts Counts, err error)) {
+	var prog *progress
+	if flags.Progress {
+		prog = newProgress(total)
+	}
+
+	workers := flags.Jobs
+	if workers < 1 {
+		workers = 1
+	}
+	window := make(chan struct{}, workers*windowPerWorker)
+	jobs := make(chan poolItem)
+	results := make(chan poolItem)
+
+	// Producer: numbers inputs in order, waiting for room in the window
+	go func() {
+		next := 0
+		send := func(item poolItem) {
+			window <- struct{}{}
+			item.index = next
+			next++
+			jobs <- item
+		}
+		produce(func(filename string) {
+			send(poolItem{filename: filename})
+		}, func(filename string, err error) {
+			send(poolItem{filename: filename, err: err})
+		})
+		close(jobs)
+	}()
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range jobs {
+				if item.err == nil {
+					item.counts, item.panic, item.err = countRecovered(item.filename, flags)
+					prog.fileDone(item.counts.Bytes)
+				}
+				results <- item
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// Collector: restores the original order, freeing a window slot for
+	// every result it hands on
+	pending := make(map[int]poolItem)
+	next := 0
+	for item := range results {
+		pending[item.index] = item
+		for {
+			ready, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+			prog.clear() // Keep the status line from mixing with the output
+			if ready.panic != nil {
+				// Rethrown here, in order, so main reports the results so far
+				prog.stop()
+				panic(ready.panic)
+			}
+			finish(ready.filename, ready.counts, ready.err)
+			<-window
+		}
+	}
+	prog.stop()
+}
+
+// countRecovered counts filename like countFile, but returns a panic
+// instead of letting it end the worker's goroutine, and with it the process.
+func countRecovered(filename string, flags Flags) (counts Counts, p *countPanic, err error) {
+	defer recoverCount(filename, &p)
+	counts, err = countFile(filename, flags)
+	return counts, p, err
+}
+
+// progress repaints a single status line on stderr while the worker pool is
+// running. Workers update the counters atomically; a ticker goroutine reads
+// them and redraws the line. A nil *progress is valid and does nothing,
+// which is what newProgress returns when stderr is not a terminal.
+type progress struct {
+	total     int
+	completed int64 // Accessed atomically
+	bytes     int64 // Accessed atomically
+
+	mu     sync.Mutex // Serializes writes to stderr
+	ticker *time.Ticker
+	quit   chan struct{}
+	done   chan struct{}
+}
+
+// newProgress starts a status line for total files (0 if the number is
+// not known in advance), or returns nil if stderr is not a terminal.
+func newProgress(total int) *progress {
+	info, err := os.Stderr.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return nil
+	}
+
+	p := &progress{
+		total:  total,
+		ticker: time.NewTicker(200 * time.Millisecond),
+		quit:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go func() {
+		defer close(p.done)
+		for {
+			select {
+			case <-p.ticker.C:
+				p.paint()
+			case <-p.quit:
+				return
+			}
+		}
+	}()
+	return p
+}
+
+// fileDone records that a worker finished a file of the given size.
+func (p *progress) fileDone(bytes int64) {
+	if p == nil {
+		return
+	}
+	atomic.AddInt64(&p.completed, 1)
+	atomic.AddInt64(&p.bytes, bytes)
+}
+
+// paint redraws the status line.
+func (p *progress) paint() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	completed, bytes := atomic.LoadInt64(&p.completed), atomic.LoadInt64(&p.bytes)
+	if p.total > 0 {
+		fmt.Fprintf(os.Stderr, "\r\033[K%d/%d files, %d bytes", completed, p.total, bytes)
+	} else {
+		fmt.Fprintf(os.Stderr, "\r\033[K%d files, %d bytes", completed, bytes)
+	}
+}
+
+// clear erases the status line; the next tick draws it again.
+func (p *progress) clear() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fmt.Fprint(os.Stderr, "\r\033[K")
+}
+
+// stop ends the repainting and erases the status line for good.
+func (p *progress) stop() {
+	if p == nil {
+		return
+	}
+	p.ticker.Stop()
+	close(p.quit)
+	<-p.done
+	p.clear()
+}
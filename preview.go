@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"strconv"
+	"unicode/utf8"
+)
+
+// LinePreview holds the first and last line of an input for -preview.
+type LinePreview struct {
+	First string
+	Last  string
+}
+
+// linePreview collects the first and last line of an input as the counter
+// sees its bytes. Only the first width characters of a line are kept.
+type linePreview struct {
+	width     int
+	cur       []byte
+	truncated bool // Did the current line go beyond what cur keeps?
+	first     []byte
+	haveFirst bool
+	last      []byte
+}
+
+// add appends one byte to the current line. Up to UTFMax bytes per
+// character are kept, so the line can be trimmed to whole characters later.
+func (p *linePreview) add(char byte) {
+	if len(p.cur) < p.width*utf8.UTFMax {
+		p.cur = append(p.cur, char)
+	} else {
+		p.truncated = true
+	}
+}
+
+// endLine completes the current line.
+func (p *linePreview) endLine() {
+	line := p.trimmed()
+	if !p.haveFirst {
+		p.first, p.haveFirst = line, true
+	}
+	p.last = line
+	p.cur, p.truncated = p.cur[:0], false
+}
+
+// finish completes a final line lacking a terminator and returns the result.
+func (p *linePreview) finish() *LinePreview {
+	if len(p.cur) > 0 || p.truncated {
+		p.endLine()
+	}
+	return &LinePreview{First: string(p.first), Last: string(p.last)}
+}
+
+// trimmed returns a copy of the current line cut down to width characters,
+// with "..." appended if anything was cut off. A trailing '\r' of a CRLF
+// terminator is dropped.
+func (p *linePreview) trimmed() []byte {
+	line := bytes.TrimSuffix(p.cur, []byte{'\r'})
+	truncated := p.truncated
+	for i, n := 0, 0; i < len(line); n++ {
+		if n == p.width {
+			line, truncated = line[:i], true
+			break
+		}
+		_, size := utf8.DecodeRune(line[i:])
+		i += size
+	}
+
+	out := append([]byte(nil), line...)
+	if truncated {
+		out = append(out, "..."...)
+	}
+	return out
+}
+
+// formatPreview renders a preview for the end of an output line. Both lines
+// are quoted Go-style so that control characters and invalid bytes are
+// escaped and cannot mess up the terminal.
+func formatPreview(preview *LinePreview) string {
+	return " first=" + strconv.Quote(preview.First) + " last=" + strconv.Quote(preview.Last)
+}
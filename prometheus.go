@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// prometheusGauges lists the columns that are not running totals, which
+// are therefore exposed as gauges without the _total suffix.
+var prometheusGauges = map[string]bool{
+	"max_line": true, "max_line_bytes": true,
+	"unique_words": true, "distinct_chars": true, "column_distinct": true,
+}
+
+// formatPrometheus renders the results and the total in the Prometheus text
+// exposition format for -prometheus, such as
+//
+//	# TYPE gowc_lines_total counter
+//	gowc_lines_total{file="notes.txt"} 42
+//	gowc_lines_total{file="todo.txt"} 8
+//	# TYPE gowc_lines_all_total counter
+//	gowc_lines_all_total 50
+//
+// The samples of a metric have to be grouped, so each column becomes one
+// metric, with a sample per input labeled with its name. The total is a
+// metric of its own, named with _all after the column, so that summing
+// the samples of a column over a scrape counts every input once. It is
+// left out with a single input, where it would repeat it.
+func formatPrometheus(results []fileResult, total Counts, inputs int, flags Flags) string {
+	var b strings.Builder
+	for i, name := range selectedNames(flags) {
+		metric, all, kind := "gowc_"+name+"_total", "gowc_"+name+"_all_total", "counter"
+		if prometheusGauges[name] {
+			metric, all, kind = "gowc_"+name, "gowc_"+name+"_all", "gauge"
+		}
+		fmt.Fprintf(&b, "# HELP %s The %s counted by gowc.\n", metric, proseNouns[name][1])
+		fmt.Fprintf(&b, "# TYPE %s %s\n", metric, kind)
+		for _, r := range results {
+			if r.Err != nil {
+				continue
+			}
+			fmt.Fprintf(&b, "%s{file=\"%s\"} %d\n", metric, prometheusLabel(r.Filename), selectedCounts(r.Counts, flags)[i])
+		}
+		if inputs > 1 {
+			fmt.Fprintf(&b, "# HELP %s The %s counted by gowc in all inputs.\n", all, proseNouns[name][1])
+			fmt.Fprintf(&b, "# TYPE %s %s\n", all, kind)
+			fmt.Fprintf(&b, "%s %d\n", all, selectedCounts(total, flags)[i])
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// prometheusLabel escapes a label value as the exposition format requires:
+// backslashes, double quotes and line feeds. Standard input, which has no
+// name, is labeled "-".
+func prometheusLabel(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormatPrometheusTotal(t *testing.T) {
+	flags := Flags{ShowLines: true}
+	results := []fileResult{
+		{Filename: "a.txt", Counts: Counts{Lines: 3}},
+		{Filename: "b.txt", Counts: Counts{Lines: 4}},
+	}
+	got := formatPrometheus(results, Counts{Lines: 7}, 2, flags)
+
+	// The total must not be a sample of the per-input metric
+	var sum int
+	for _, line := range strings.Split(got, "\n") {
+		if strings.HasPrefix(line, "gowc_lines_total") {
+			sum++
+		}
+	}
+	if sum != 2 || !strings.Contains(got, "\n# TYPE gowc_lines_all_total counter\ngowc_lines_all_total 7") {
+		t.Errorf("formatPrometheus =\n%s\nwant two gowc_lines_total samples and gowc_lines_all_total 7", got)
+	}
+	if single := formatPrometheus(results[:1], Counts{Lines: 3}, 1, flags); strings.Contains(single, "_all") {
+		t.Errorf("formatPrometheus of one input has a total:\n%s", single)
+	}
+}
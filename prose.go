@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// proseNouns gives the singular and plural noun for each count column in
+// -prose sentences, keyed by the names from selectedNames.
+var proseNouns = map[string][2]string{
+	"lines":            {"line", "lines"},
+	"words":            {"word", "words"},
+	"graphemes":        {"grapheme cluster", "grapheme clusters"},
+	"chars":            {"character", "characters"},
+	"bytes":            {"byte", "bytes"},
+	"truly_empty":      {"empty line", "empty lines"},
+	"whitespace_only":  {"whitespace-only line", "whitespace-only lines"},
+	"printable":        {"printable character", "printable characters"},
+	"control":          {"control character", "control characters"},
+	"whitespace_bytes": {"whitespace byte", "whitespace bytes"},
+	"content_bytes":    {"non-whitespace byte", "non-whitespace bytes"},
+	"emoji":            {"emoji", "emoji"},
+	"substr":           {"occurrence", "occurrences"},
+	"column_values":    {"value in the column", "values in the column"},
+	"column_distinct":  {"distinct value in the column", "distinct values in the column"},
+	"max_line":         {"column in the widest line", "columns in the widest line"},
+	"max_line_bytes":   {"byte in the longest line", "bytes in the longest line"},
+	"over_length":      {"overlong line", "overlong lines"},
+	"unique_words":     {"unique word", "unique words"},
+	"distinct_chars":   {"distinct character", "distinct characters"},
+	"filtered_words":   {"word without stopwords", "words without stopwords"},
+	"compressed":       {"compressed byte", "compressed bytes"},
+}
+
+// formatProse describes the enabled counts as a sentence for -prose, such
+// as "notes.txt has 42 lines, 300 words, and 1,800 bytes." subject is the
+// start of the sentence, verb included.
+func formatProse(counts Counts, flags Flags, subject string) string {
+	names := selectedNames(flags)
+	var phrases []string
+	for i, value := range selectedCounts(counts, flags) {
+		noun := proseNouns[names[i]][1]
+		if value == 1 {
+			noun = proseNouns[names[i]][0]
+		}
+		phrases = append(phrases, formatCount(value, flags)+" "+noun)
+	}
+	return fmt.Sprintf("%s %s.", subject, joinProse(phrases))
+}
+
+// proseSubject starts the -prose sentence about the named input.
+func proseSubject(filename string) string {
+	if filename == "" {
+		return "Standard input has"
+	}
+	return filename + " has"
+}
+
+// proseTotalSubject starts the closing -prose sentence about the total.
+func proseTotalSubject(inputs int) string {
+	return fmt.Sprintf("Together, the %d inputs have", inputs)
+}
+
+// joinProse joins phrases as an English list: "a", "a and b", "a, b, and c".
+func joinProse(phrases []string) string {
+	switch len(phrases) {
+	case 0:
+		return "nothing to report"
+	case 1:
+		return phrases[0]
+	case 2:
+		return phrases[0] + " and " + phrases[1]
+	}
+	return strings.Join(phrases[:len(phrases)-1], ", ") + ", and " + phrases[len(phrases)-1]
+}
+
+// formatCount formats a count, grouping its digits by thousands with
+// -thousands.
+func formatCount(value int64, flags Flags) string {
+	digits := strconv.FormatInt(value, 10)
+	if !flags.Thousands {
+		return digits
+	}
+
+	sign := ""
+	if value < 0 {
+		sign, digits = "-", digits[1:]
+	}
+	var b strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(d)
+	}
+	return sign + b.String()
+}
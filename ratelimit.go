@@ -0,0 +1,61 @@
+package main
+
+import (
+	"io"
+	"time"
+)
+
+// rateLimitedReader throttles reads from an underlying reader to a fixed
+// number of bytes per second using a simple token bucket. The bucket holds
+// at most one second's worth of tokens (capped at bufferSize), so short
+// bursts are smoothed out rather than allowed to saturate the disk.
+type rateLimitedReader struct {
+	reader io.Reader
+	rate   float64 // Bytes per second
+	burst  int     // Maximum number of bytes handed out by a single Read
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimitedReader wraps reader so that it yields at most bytesPerSec
+// bytes per second. bytesPerSec must be positive.
+func newRateLimitedReader(reader io.Reader, bytesPerSec int64) *rateLimitedReader {
+	burst := bufferSize
+	if bytesPerSec < int64(burst) {
+		burst = int(bytesPerSec)
+	}
+	return &rateLimitedReader{
+		reader: reader,
+		rate:   float64(bytesPerSec),
+		burst:  burst,
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// refill adds the tokens earned since the last call, up to the burst size.
+func (l *rateLimitedReader) refill() {
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > float64(l.burst) {
+		l.tokens = float64(l.burst)
+	}
+	l.last = now
+}
+
+func (l *rateLimitedReader) Read(p []byte) (int, error) {
+	if len(p) > l.burst {
+		p = p[:l.burst]
+	}
+
+	// Wait until enough tokens are available for the whole request
+	l.refill()
+	if need := float64(len(p)) - l.tokens; need > 0 {
+		time.Sleep(time.Duration(need / l.rate * float64(time.Second)))
+		l.refill()
+	}
+
+	n, err := l.reader.Read(p)
+	l.tokens -= float64(n)
+	return n, err
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"unicode"
+)
+
+// Readability holds the counts behind the -readability metrics. Its words
+// are runs of letters, digits and apostrophes, which can differ from the
+// whitespace-separated word count.
+//
+// Periods, Exclamations and Questions break the sentences down by the
+// character that ended them, the first of a run like "?!"; a last sentence
+// lacking its final punctuation is Unterminated.
+type Readability struct {
+	Sentences  int64
+	Paragraphs int64
+	Words      int64
+	Syllables  int64
+
+	Periods      int64
+	Exclamations int64
+	Questions    int64
+	Unterminated int64
+}
+
+// Merge adds the counts of other to r.
+func (r *Readability) Merge(other *Readability) {
+	r.Sentences += other.Sentences
+	r.Paragraphs += other.Paragraphs
+	r.Words += other.Words
+	r.Syllables += other.Syllables
+	r.Periods += other.Periods
+	r.Exclamations += other.Exclamations
+	r.Questions += other.Questions
+	r.Unterminated += other.Unterminated
+}
+
+// SentencesPerParagraph, WordsPerSentence and Flesch return 0 for text
+// without the paragraphs, sentences or words to divide by.
+func (r *Readability) SentencesPerParagraph() float64 {
+	if r.Paragraphs == 0 {
+		return 0
+	}
+	return float64(r.Sentences) / float64(r.Paragraphs)
+}
+
+func (r *Readability) WordsPerSentence() float64 {
+	if r.Sentences == 0 {
+		return 0
+	}
+	return float64(r.Words) / float64(r.Sentences)
+}
+
+// Flesch returns the Flesch reading-ease score: around 60 to 70 for plain
+// English, higher for easier text, lower for harder.
+func (r *Readability) Flesch() float64 {
+	if r.Words == 0 || r.Sentences == 0 {
+		return 0
+	}
+	return 206.835 - 1.015*r.WordsPerSentence() - 84.6*float64(r.Syllables)/float64(r.Words)
+}
+
+// readabilityCounter finds sentences, paragraphs and syllables in a stream
+// of characters. A sentence ends at a run of '.', '!' or '?' following a
+// word; a paragraph is a block of non-blank lines. Both are heuristics: an
+// abbreviation like "e.g." ends a sentence too.
+type readabilityCounter struct {
+	stats Readability
+
+	word        []rune // Lowercased letters of the current word
+	inWord      bool
+	pendingWord bool // Has a word been seen since the last sentence ended?
+	inParagraph bool
+	lineBlank   bool
+}
+
+func newReadabilityCounter() *readabilityCounter {
+	return &readabilityCounter{lineBlank: true}
+}
+
+// add considers the next character.
+func (rc *readabilityCounter) add(r rune) {
+	if unicode.IsLetter(r) || unicode.IsDigit(r) || (rc.inWord && (r == '\'' || r == '’')) {
+		rc.word = append(rc.word, unicode.ToLower(r))
+		rc.inWord = true
+	} else {
+		rc.endWord()
+	}
+
+	switch {
+	case r == '.' || r == '!' || r == '?':
+		if rc.pendingWord {
+			rc.stats.Sentences++
+			rc.pendingWord = false
+			switch r {
+			case '.':
+				rc.stats.Periods++
+			case '!':
+				rc.stats.Exclamations++
+			default:
+				rc.stats.Questions++
+			}
+		}
+	case r == '\n':
+		if rc.lineBlank {
+			rc.inParagraph = false
+		}
+		rc.lineBlank = true
+		return
+	}
+	if !unicode.IsSpace(r) {
+		rc.lineBlank = false
+		if !rc.inParagraph {
+			rc.stats.Paragraphs++
+			rc.inParagraph = true
+		}
+	}
+}
+
+// endWord counts the word just completed, if any, and its syllables.
+func (rc *readabilityCounter) endWord() {
+	if !rc.inWord {
+		return
+	}
+	rc.stats.Words++
+	rc.stats.Syllables += syllables(rc.word)
+	rc.pendingWord = true
+	rc.word = rc.word[:0]
+	rc.inWord = false
+}
+
+// finish ends the last word, and with it a last sentence lacking its final
+// punctuation.
+func (rc *readabilityCounter) finish() *Readability {
+	rc.endWord()
+	if rc.pendingWord {
+		rc.stats.Sentences++
+		rc.stats.Unterminated++
+		rc.pendingWord = false
+	}
+	return &rc.stats
+}
+
+// syllables estimates the syllables of a lowercased English word as its
+// groups of vowels, less a silent final 'e' ("make", but not "table"), and
+// at least one. Words without Latin vowels, numbers for instance, count as
+// one syllable.
+func syllables(word []rune) int64 {
+	isVowel := func(r rune) bool {
+		switch r {
+		case 'a', 'e', 'i', 'o', 'u', 'y':
+			return true
+		}
+		return false
+	}
+
+	var n int64
+	prevVowel := false
+	for _, r := range word {
+		vowel := isVowel(r)
+		if vowel && !prevVowel {
+			n++
+		}
+		prevVowel = vowel
+	}
+	if l := len(word); l > 2 && word[l-1] == 'e' && !isVowel(word[l-2]) && !(word[l-2] == 'l' && !isVowel(word[l-3])) {
+		n--
+	}
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+// formatReadability formats the -readability line printed below the counts.
+func formatReadability(r *Readability) string {
+	if r == nil {
+		r = &Readability{}
+	}
+	return fmt.Sprintf("    readability: %d sentences in %d paragraphs, %.1f sentences per paragraph, %.1f words per sentence, Flesch reading ease %.1f (approximate)",
+		r.Sentences, r.Paragraphs, r.SentencesPerParagraph(), r.WordsPerSentence(), r.Flesch())
+}
+
+// formatSentenceTypes formats the -sentence-types line, which counts the
+// sentences ending in each kind of punctuation.
+func formatSentenceTypes(r *Readability) string {
+	if r == nil {
+		r = &Readability{}
+	}
+	return fmt.Sprintf("    sentence types: %d '.', %d '!', %d '?', %d unterminated", r.Periods, r.Exclamations, r.Questions, r.Unterminated)
+}
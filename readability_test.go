@@ -0,0 +1,19 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormatReadability(t *testing.T) {
+	flags := Flags{ShowWords: true, MinWordLen: 1, Readability: true}
+	counts, err := count(strings.NewReader("The cat sat. The dog ran!\n\nIt rained.\n"), flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	details := formatDetails(counts, flags)
+	want := "    readability: 3 sentences in 2 paragraphs, 1.5 sentences per paragraph, 2.7 words per sentence,"
+	if len(details) == 0 || !strings.HasPrefix(details[0], want) {
+		t.Errorf("formatDetails = %q, want a first line starting with %q", details, want)
+	}
+}
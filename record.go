@@ -0,0 +1,13 @@
+package main
+
+// recordCount returns the number of fixed-length records in n bytes, for
+// -record-length: a final record shorter than length only counts with
+// partial (-partial-record), the way a last line lacking its terminator
+// does not count as a line either.
+func recordCount(n, length int64, partial bool) int64 {
+	records := n / length
+	if partial && n%length != 0 {
+		records++
+	}
+	return records
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// fileResult pairs the counts of one input with the name it is reported under.
+// An empty Filename means standard input. With -json-errors an input that
+// could not be counted is recorded too, with Err set instead of Counts.
+type fileResult struct {
+	Filename string
+	Counts   Counts
+	Err      error
+}
+
+// sortResults orders results by filename. The sort is stable, so results
+// already in a meaningful order keep it among equal names.
+func sortResults(results []fileResult) {
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Filename < results[j].Filename
+	})
+}
+
+// formatEOLReport describes the line terminators found, flagging inputs
+// that mix more than one kind.
+func formatEOLReport(counts Counts) string {
+	kinds := 0
+	for _, n := range []int64{counts.EOLLF, counts.EOLCRLF, counts.EOLCR} {
+		if n > 0 {
+			kinds++
+		}
+	}
+	report := fmt.Sprintf("    eol: lf=%d crlf=%d cr=%d", counts.EOLLF, counts.EOLCRLF, counts.EOLCR)
+	if kinds > 1 {
+		report += " (mixed)"
+	}
+	return report
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// wordRuneFunc returns the predicate deciding which characters belong to a
+// word, or nil when the default byte-wise whitespace splitting applies.
+func wordRuneFunc(flags Flags) func(r rune) bool {
+	if flags.WordChars != "" {
+		set := flags.WordChars
+		return func(r rune) bool {
+			// Combining marks belong to the letter they modify
+			return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r) ||
+				strings.ContainsRune(set, r)
+		}
+	}
+	if flags.UnicodeSpace {
+		return func(r rune) bool {
+			return !unicode.IsSpace(r)
+		}
+	}
+	if flags.Segment != "" {
+		// Punctuation separates words too, as no space may follow it
+		return func(r rune) bool {
+			return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r)
+		}
+	}
+	return nil
+}
+
+// feedRunes decodes buf as UTF-8 and hands every character to onRune. The
+// bytes of a character split across two chunks are carried over and joined
+// with the start of the next chunk. Invalid bytes decode to U+FFFD.
+func (c *counter) feedRunes(buf []byte) {
+	data := buf
+	if len(c.carry) > 0 {
+		c.scratch = append(append(c.scratch[:0], c.carry...), buf...)
+		c.carry = c.carry[:0]
+		data = c.scratch
+	}
+
+	for i := 0; i < len(data); {
+		r, size := rune(data[i]), 1
+		if r >= utf8.RuneSelf {
+			if !utf8.FullRune(data[i:]) {
+				c.carry = append(c.carry, data[i:]...)
+				return
+			}
+			r, size = utf8.DecodeRune(data[i:])
+		}
+		i += size
+		c.onRune(r, r == utf8.RuneError && size == 1)
+	}
+}
+
+// finishRunes decodes the bytes left over at the end of the input; they can
+// no longer become a complete character.
+func (c *counter) finishRunes() {
+	for range c.carry {
+		c.onRune(utf8.RuneError, true)
+	}
+	c.carry = c.carry[:0]
+}
+
+// wordRune adds the word character r to the current word, starting one if
+// the previous character ended a word.
+func (c *counter) wordRune(r rune) {
+	if !c.inWord {
+		if c.flags.MinWordLen <= 1 {
+			c.counts.Words++ // Otherwise endWord decides
+		}
+		c.inWord = true
+	}
+	c.wordLen++
+	if c.captureWords {
+		c.addWordRune(r)
+	}
+}
+
+// breakWord ends the current word, if any.
+func (c *counter) breakWord() {
+	if c.inWord && c.trackWords {
+		c.endWord()
+	}
+	c.inWord = false
+}
+
+// onRune updates the character-level metrics with one decoded character.
+// invalid is set for a byte that is not part of valid UTF-8; it still
+// counts as a (non-word) character, but as neither printable nor control.
+func (c *counter) onRune(r rune, invalid bool) {
+	if c.segmenter != nil {
+		c.segmenter.add(r, c.isWordRune(r)) // Hands the words to wordRune and breakWord
+	} else if c.isWordRune != nil {
+		if c.isWordRune(r) {
+			c.wordRune(r)
+		} else {
+			c.breakWord()
+		}
+	}
+
+	if c.graphemes != nil {
+		c.graphemes.add(r) // An invalid byte is a cluster of its own
+	}
+	if c.readability != nil {
+		c.readability.add(r)
+	}
+
+	if invalid {
+		return
+	}
+	if c.counts.Runes != nil {
+		c.counts.Runes[r] = true
+	}
+	if c.flags.ShowPrintable && unicode.IsPrint(r) {
+		c.counts.Printable++
+	}
+	if c.flags.ShowControl && unicode.IsControl(r) {
+		c.counts.Control++
+	}
+	if c.flags.ShowEmoji {
+		c.onEmojiRune(r)
+	}
+	if c.counts.Scripts != nil {
+		c.counts.Scripts[scriptOf(r, c.scriptCache)]++
+	}
+}
+
+// sortedRunes returns the characters of a -distinct-chars set in code point
+// order.
+func sortedRunes(set map[rune]bool) []rune {
+	runes := make([]rune, 0, len(set))
+	for r := range set {
+		runes = append(runes, r)
+	}
+	sort.Slice(runes, func(i, j int) bool { return runes[i] < runes[j] })
+	return runes
+}
+
+// formatChars lists the distinct characters for -list-chars, quoted
+// Go-style so that whitespace and control characters are visible.
+func formatChars(set map[rune]bool) string {
+	return "    chars: " + strconv.Quote(string(sortedRunes(set)))
+}
@@ -0,0 +1,38 @@
+package main
+
+import "testing"
+
+func TestUnicodeSpace(t *testing.T) {
+	tests := []struct {
+		text            string
+		bytewise, runes int64 // Words without and with -unicode-space
+	}{
+		{"no\u00a0break", 2, 2},           // NBSP splits bytewise only by its 0xA0 byte
+		{"日本\u3000語", 1, 2},               // Ideographic space: E3 80 80
+		{"one\u3000two\u00a0three", 2, 3}, // Both kinds in one line
+		{"là-bas", 2, 1},                  // "à" is C3 A0, which splits bytewise
+		{"\u3000\u00a0", 1, 0},            // Only white space
+	}
+	for _, tt := range tests {
+		for _, unicodeSpace := range []bool{false, true} {
+			want := tt.bytewise
+			if unicodeSpace {
+				want = tt.runes
+			}
+
+			// Every byte its own chunk too, to split each space across feeds
+			for _, chunk := range []int{1, len(tt.text)} {
+				c := newCounter(Flags{ShowWords: true, MinWordLen: 1, UnicodeSpace: unicodeSpace})
+				for data := []byte(tt.text); len(data) > 0; data = data[chunk:] {
+					if chunk > len(data) {
+						chunk = len(data)
+					}
+					c.Feed(data[:chunk])
+				}
+				if got := c.Result().(Counts).Words; got != want {
+					t.Errorf("-unicode-space=%v, %q in chunks of %d: %d words, want %d", unicodeSpace, tt.text, chunk, got, want)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+)
+
+// sampleReader passes on only every every'th chunk of size bytes of src,
+// for -sample: the chunks in between are seeked past when src is a
+// regular file, so they are never even read, and read and discarded when
+// it is not. It tallies how many bytes it passed on and how many it
+// skipped, for the counts to be scaled up afterwards (see scaleSample).
+type sampleReader struct {
+	src   io.Reader
+	every int64
+	size  int64
+
+	end     int64 // Size of a seekable src, or -1
+	pos     int64 // Offset in a seekable src
+	left    int64 // Bytes left to pass on from the current chunk
+	sampled int64
+	skipped int64
+}
+
+func newSampleReader(src io.Reader, every int, size int) *sampleReader {
+	s := &sampleReader{src: src, every: int64(every), size: int64(size), left: int64(size), end: -1}
+
+	// Only seek what really is a file: a pipe fails to seek, and anything
+	// wrapped around a file is no io.Seeker to begin with
+	if seeker, ok := src.(io.Seeker); ok {
+		pos, err := seeker.Seek(0, io.SeekCurrent)
+		if err == nil {
+			end, err := seeker.Seek(0, io.SeekEnd)
+			if err == nil {
+				if _, err := seeker.Seek(pos, io.SeekStart); err == nil {
+					s.pos, s.end = pos, end
+				}
+			}
+		}
+	}
+	return s
+}
+
+func (s *sampleReader) Read(p []byte) (int, error) {
+	if s.left == 0 {
+		if err := s.skip(); err != nil {
+			return 0, err
+		}
+		s.left = s.size
+	}
+	if int64(len(p)) > s.left {
+		p = p[:s.left]
+	}
+	n, err := s.src.Read(p)
+	s.left -= int64(n)
+	s.sampled += int64(n)
+	s.pos += int64(n)
+	return n, err
+}
+
+// skip goes past the every-1 chunks following a sampled one.
+func (s *sampleReader) skip() error {
+	gap := (s.every - 1) * s.size
+	if s.end >= 0 {
+		if s.pos+gap > s.end {
+			gap = s.end - s.pos
+		}
+		if _, err := s.src.(io.Seeker).Seek(gap, io.SeekCurrent); err != nil {
+			return fmt.Errorf("error skipping a -sample gap: %w", err)
+		}
+		s.pos += gap
+		s.skipped += gap
+		return nil
+	}
+	n, err := io.CopyN(io.Discard, s.src, gap)
+	s.skipped += n
+	if err != nil && err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+// scaleSample turns the counts of the sampled chunks into estimates for the
+// whole input: the byte count becomes exact again, and the line, word and
+// character counts grow in proportion. The other counts, such as -readability
+// or -top, describe the sample alone.
+func scaleSample(counts *Counts, s *sampleReader) {
+	counts.SkippedBytes = s.skipped
+	if s.skipped == 0 || counts.Bytes == 0 {
+		return
+	}
+	factor := float64(counts.Bytes+s.skipped) / float64(counts.Bytes)
+	scale := func(n int64) int64 {
+		return int64(math.Round(float64(n) * factor))
+	}
+	counts.Lines = scale(counts.Lines)
+	counts.Words = scale(counts.Words)
+	counts.Chars = scale(counts.Chars)
+	counts.Bytes += s.skipped
+}
+
+// formatSample formats the -sample line, which marks the counts above it as
+// estimates.
+func formatSample(counts Counts) string {
+	return fmt.Sprintf("    estimated: lines, words and characters scaled up from a sample of %d of %d bytes",
+		counts.Bytes-counts.SkippedBytes, counts.Bytes)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// scriptNames lists the scripts known to the unicode package, sorted so
+// that lookups do not depend on map iteration order.
+var scriptNames = func() []string {
+	names := make([]string, 0, len(unicode.Scripts))
+	for name := range unicode.Scripts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}()
+
+// scriptOf returns the name of the Unicode script r belongs to: "Common"
+// for characters shared between scripts such as digits and punctuation,
+// "Unknown" for unassigned code points. Searching the tables is slow, so
+// results are remembered in cache, which is per counter.
+func scriptOf(r rune, cache map[rune]string) string {
+	if r < 0x80 {
+		if 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' {
+			return "Latin"
+		}
+		return "Common"
+	}
+	if name, ok := cache[r]; ok {
+		return name
+	}
+	name := "Unknown"
+	for _, s := range scriptNames {
+		if unicode.Is(unicode.Scripts[s], r) {
+			name = s
+			break
+		}
+	}
+	cache[r] = name
+	return name
+}
+
+// formatScripts describes the -script breakdown, most frequent script
+// first, e.g. "    scripts: Latin=120 Common=40 Cyrillic=3".
+func formatScripts(scripts map[string]int64) string {
+	if len(scripts) == 0 {
+		return "    scripts: none"
+	}
+	names := make([]string, 0, len(scripts))
+	for name := range scripts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if scripts[names[i]] != scripts[names[j]] {
+			return scripts[names[i]] > scripts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+
+	parts := []string{"    scripts:"}
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("%s=%d", name, scripts[name]))
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,77 @@
+package main
+
+// segmentWords holds the built-in -segment dictionaries: common words of
+// each language, separated by white space. They are small on purpose, to
+// keep the binary small; a word they lack is split by the fallback rules
+// of segmenter, and -segment-dict adds the words of a fuller list, such as
+// one derived from CC-CEDICT for Chinese or from the ICU dictionaries.
+var segmentWords = map[string]string{
+	segmentChinese: `
+		我们 你们 他们 她们 它们 咱们 自己 大家 别人 人们
+		什么 怎么 怎样 怎么样 为什么 这个 那个 哪个 这些 那些 这里 那里 哪里
+		这样 那样 这么 那么 多少 几个 一些 一下 一点 一起 一样 一定 一直 一般
+		现在 今天 明天 昨天 今年 明年 去年 时候 时间 以前 以后 之前 之后 刚才
+		早上 上午 中午 下午 晚上 星期 周末 小时 分钟 每天 天天 最近 将来 过去
+		已经 还是 或者 但是 可是 因为 所以 如果 虽然 然后 而且 不过 只是 就是
+		可以 可能 应该 需要 知道 觉得 认为 希望 喜欢 发现 开始 结束 继续 出现
+		发生 进行 工作 学习 研究 讨论 解决 认识 了解 明白 记得 忘记 相信 同意
+		告诉 介绍 帮助 准备 决定 选择 参加 欢迎 谢谢 对不起 没关系 再见 你好
+		吃饭 睡觉 起床 上班 下班 回家 旅游 运动 休息 唱歌 跳舞 购物 打电话
+		学生 老师 学校 大学 中学 小学 同学 同事 朋友 家人 孩子 父母 爸爸 妈妈
+		先生 女士 男人 女人 医生 公司 医院 银行 商店 饭店 酒店 机场 车站 公园
+		图书馆 地方 城市 国家 世界 中国 中国人 美国 日本 英国 法国 德国 北京 上海
+		香港 台湾 中华 人民 共和国 政府 社会 经济 政治 文化 历史 科学 技术 教育
+		中文 汉语 英语 日语 语言 文字 汉字 电脑 手机 电话 电视 电影 音乐 新闻
+		计算机 软件 程序 数据 网络 互联网 系统 信息 文件 设计 开发 用户 服务 产品
+		市场 价格 问题 办法 方法 情况 事情 东西 意思 关系 机会 经验 能力 生活
+		身体 健康 天气 环境 自然 发展 建设 重要 主要 简单 容易 困难 漂亮 高兴
+		快乐 开心 舒服 方便 安全 危险 干净 普通 正常 非常 特别 比较 真的 当然
+		马上 终于 突然 其实 大概 也许 差不多 没有 不是 不要 不会 不能 所有 每个
+		很多 许多 第一 最后 中间 旁边 前面 后面 上面 下面 里面 外面 左边 右边
+		火车 飞机 汽车 自行车 地铁 咖啡 米饭 面条 水果 早饭 午饭 晚饭
+		好吃 好看 好听 好用 可爱 一个 两个 这次 那次 看见 听见 看到 听到 找到
+	`,
+
+	segmentJapanese: `
+		は が を に で と も の へ や か ね よ から まで より けど
+		です でした ではない じゃない ます ました ません ませんでした
+		する します しました しません して した している しています います いる
+		ある あります あった ない なかった なる なります なった
+		そして でも しかし それから だから とても もう まだ よく すこし 少し
+		これ それ あれ どれ この その あの どの ここ そこ あそこ どこ
+		わたし 私 僕 彼 彼女 あなた 皆さん 誰 何 今 いつ
+		日本 日本語 日本人 東京 大阪 京都 英語 中国 中国語 外国 外国人
+		学生 先生 学校 大学 会社 会社員 仕事 勉強 電話 電車 時間 今日 明日 昨日
+		毎日 今年 去年 来年 天気 元気 友達 家族 子供 母 父 名前 言葉 料理
+		食べる 食べます 食べた 食べて 飲む 飲みます 飲んだ 飲んで 見る 見ます 見た 見て
+		行く 行きます 行った 行って 来る 来ます 来た 来て 書く 書きます 書いた 書いて
+		読む 読みます 読んだ 読んで 話す 話します 話した 話して 聞く 聞きます 聞いた 聞いて
+		買う 買います 買った 買って 思う 思います 思った 言う 言います 言った
+		住む 住んで 働く 働いて 分かる 分かります 分かった 知る 知って
+		好き 大好き 嫌い 大きい 小さい 新しい 古い 高い 安い 美しい 面白い 楽しい
+		難しい 易しい 暑い 寒い 早い 遅い 多い 少ない 良い いい 悪い
+		本 水 人 山 川 車 駅 店 花 猫 犬 雨 朝 夜 家 国 町 海 空 手 目
+		ありがとう ございます おはよう こんにちは こんばんは すみません ください
+	`,
+
+	segmentThai: `
+		ผม ฉัน ดิฉัน เธอ เขา เรา คุณ ท่าน พวกเรา พวกเขา ตัวเอง
+		กิน ข้าว น้ำ ดื่ม นอน ไป มา อยู่ ทำ ทำงาน เรียน เรียนรู้ รู้ รู้จัก เข้าใจ
+		ชอบ รัก อยาก ต้องการ ต้อง ได้ ให้ ใช้ มี เป็น คือ ไม่ ไม่ใช่ ใช่ ไม่เป็นไร
+		ครับ ค่ะ คะ นะ จะ แล้ว กำลัง เคย ยัง ก็ และ หรือ แต่ เพราะ ถ้า ว่า ที่ ของ
+		ใน บน ใต้ กับ จาก ถึง เพื่อ โดย สำหรับ นี้ นั้น โน้น นี่ นั่น
+		สวัสดี ขอบคุณ ขอโทษ ลาก่อน ยินดี
+		ภาษา ไทย ภาษาไทย อังกฤษ ภาษาอังกฤษ จีน ญี่ปุ่น ประเทศ ประเทศไทย
+		คน คนไทย เมือง กรุงเทพ บ้าน โรงเรียน มหาวิทยาลัย นักเรียน ครู เพื่อน
+		พ่อ แม่ ลูก พี่ น้อง ครอบครัว
+		วัน วันนี้ พรุ่งนี้ เมื่อวาน เวลา ตอนนี้ ปี เดือน สัปดาห์ ชั่วโมง นาที
+		เช้า เย็น กลางคืน อากาศ ฝน ตก
+		ดี มาก น้อย ใหญ่ เล็ก สวย ง่าย ยาก ร้อน หนาว อร่อย สนุก สบาย ใหม่ เก่า
+		เร็ว ช้า ถูก แพง
+		อาหาร ผลไม้ กาแฟ ชา รถ รถไฟ เครื่องบิน ถนน ตลาด ร้าน ร้านอาหาร โรงแรม
+		โรงพยาบาล ห้อง ห้องน้ำ หนังสือ อ่าน เขียน พูด ฟัง ดู เห็น ซื้อ ขาย เงิน บาท
+		ทำไม อะไร ที่ไหน เมื่อไร อย่างไร ยังไง เท่าไร กี่ ทุก ทุกวัน บาง หลาย
+		คอมพิวเตอร์ โทรศัพท์ มือถือ อินเทอร์เน็ต งาน บริษัท ปัญหา ความ ความรัก
+		ความสุข ชีวิต โลก แมว หมา
+	`,
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Languages -segment knows how to split into words.
+const (
+	segmentChinese  = "zh"
+	segmentJapanese = "ja"
+	segmentKorean   = "ko"
+	segmentThai     = "th"
+)
+
+// Character classes the segmentation rules tell apart.
+const (
+	segNone  = iota // No word character before, or a word just ended
+	segOther        // Latin, Hangul, digits and anything else
+	segHan
+	segHiragana
+	segKatakana
+	segThai
+)
+
+// maxSegmentRun bounds the characters a segmenter holds back. A longer
+// run without a break is segmented in pieces of this size, which may cut
+// one word in two but keeps the memory and the work per run bounded.
+const maxSegmentRun = 1024
+
+// segmenter splits runs of word characters into words like ICU's
+// dictionary-based word break iterator does, for languages that do not put
+// spaces between their words:
+//
+//   - zh: runs of Han characters are split at the words of the dictionary;
+//     a character that starts no dictionary word is a word of its own, as
+//     Chinese word counts are usually taken.
+//   - ja: runs of kanji and hiragana are split at the words of the
+//     dictionary, particles and verb endings included; unknown kanji in a
+//     row count as one compound, and so do unknown hiragana. A katakana
+//     run is one word.
+//   - th: runs of Thai are split at the words of the dictionary; unknown
+//     text in between counts as one word. A break never falls inside a
+//     syllable cluster: not before a vowel sign or tone mark, not after a
+//     leading vowel.
+//   - ko: Korean puts spaces between its words, so only punctuation splits
+//     words that whitespace would not.
+//
+// Runs of other letters (a Latin name, a number) are one word. The built-in
+// dictionaries only hold common words; -segment-dict adds a fuller list.
+// The counts therefore depend on the language and the dictionary.
+//
+// The characters of a run are held back until it ends, and then handed on
+// word by word: char adds a character to the current word, starting one if
+// need be, and end ends it.
+type segmenter struct {
+	lang string
+	dict *segmentDict
+	char func(r rune)
+	end  func()
+
+	prev int    // Class of the previous character handed on
+	run  []rune // Characters held back for the dictionary
+}
+
+// newSegmenter returns the segmenter for lang, one of the segment*
+// constants (checked by Flags.validate), with dict as its dictionary if it
+// is one for lang, or else the built-in one.
+func newSegmenter(lang string, dict *segmentDict, char func(r rune), end func()) *segmenter {
+	if dict == nil || dict.lang != lang {
+		dict = builtinSegmentDict(lang)
+	}
+	return &segmenter{lang: lang, dict: dict, char: char, end: end}
+}
+
+// checkSegmentLang reports an error for a -segment language without rules.
+func checkSegmentLang(lang string) error {
+	switch lang {
+	case segmentChinese, segmentJapanese, segmentKorean, segmentThai:
+		return nil
+	}
+	return fmt.Errorf("unsupported segmentation language %q (want zh, ja, ko or th)", lang)
+}
+
+// segmentClass returns the class of the word character r.
+func segmentClass(r rune) int {
+	switch {
+	case unicode.Is(unicode.Han, r):
+		return segHan
+	case unicode.Is(unicode.Hiragana, r):
+		return segHiragana
+	case unicode.Is(unicode.Katakana, r), r == 0x30FC, r == 0xFF70:
+		return segKatakana // The prolonged sound mark goes with katakana words
+	case unicode.Is(unicode.Thai, r):
+		return segThai
+	}
+	return segOther
+}
+
+// heldBack reports whether characters of class are segmented with the
+// dictionary of the language.
+func (s *segmenter) heldBack(class int) bool {
+	switch s.lang {
+	case segmentChinese:
+		return class == segHan
+	case segmentJapanese:
+		return class == segHan || class == segHiragana
+	case segmentThai:
+		return class == segThai
+	}
+	return false
+}
+
+// add considers the next character; word tells whether it is a word
+// character at all.
+func (s *segmenter) add(r rune, word bool) {
+	if !word {
+		s.flush()
+		s.end()
+		s.prev = segNone
+		return
+	}
+
+	// A combining mark belongs to the character before it
+	if unicode.IsMark(r) && (len(s.run) > 0 || s.prev != segNone) {
+		if len(s.run) > 0 {
+			s.run = append(s.run, r)
+		} else {
+			s.char(r)
+		}
+		return
+	}
+
+	class := segmentClass(r)
+	if s.heldBack(class) {
+		if len(s.run) == 0 {
+			s.end() // The run holds words of its own
+		}
+		s.run = append(s.run, r)
+		if len(s.run) >= maxSegmentRun {
+			s.flush()
+		}
+		return
+	}
+
+	s.flush()
+	if s.prev != segNone && s.prev != class && s.lang == segmentJapanese {
+		s.end() // A katakana word next to Latin letters, say
+	}
+	s.char(r)
+	s.prev = class
+}
+
+// flush segments the characters held back and hands them on, ending the
+// last of their words.
+func (s *segmenter) flush() {
+	if len(s.run) == 0 {
+		return
+	}
+	for _, word := range s.dict.segment(s.run, s.lang) {
+		for _, r := range word {
+			s.char(r)
+		}
+		s.end()
+	}
+	s.run = s.run[:0]
+	s.prev = segNone
+}
+
+// segmentDict is a set of words to segment text by.
+type segmentDict struct {
+	lang   string
+	words  map[string]bool
+	maxLen int // Characters in the longest word
+}
+
+func newSegmentDict(lang string) *segmentDict {
+	return &segmentDict{lang: lang, words: make(map[string]bool)}
+}
+
+// add adds the words in list, separated by white space.
+func (d *segmentDict) add(list string) {
+	for _, word := range strings.Fields(list) {
+		d.words[word] = true
+		if n := utf8.RuneCountInString(word); n > d.maxLen {
+			d.maxLen = n
+		}
+	}
+}
+
+var (
+	builtinDictsMu sync.Mutex
+	builtinDicts   = make(map[string]*segmentDict)
+)
+
+// builtinSegmentDict returns the built-in dictionary for lang, made once
+// from the word lists in segdict.go and shared by every segmenter after.
+func builtinSegmentDict(lang string) *segmentDict {
+	builtinDictsMu.Lock()
+	defer builtinDictsMu.Unlock()
+	d := builtinDicts[lang]
+	if d == nil {
+		d = newSegmentDict(lang)
+		d.add(segmentWords[lang])
+		builtinDicts[lang] = d
+	}
+	return d
+}
+
+// loadSegmentDict reads the -segment-dict file, one word per line, and
+// returns the built-in dictionary for lang extended with its words.
+func loadSegmentDict(path, lang string) (*segmentDict, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	d := newSegmentDict(lang)
+	d.add(segmentWords[lang])
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		d.add(scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// segmentCost ranks the ways to split a run: first by how many of its
+// characters are in no dictionary word, then by how many words it makes.
+type segmentCost struct {
+	unknown, words int
+}
+
+func (c segmentCost) less(other segmentCost) bool {
+	if c.unknown != other.unknown {
+		return c.unknown < other.unknown
+	}
+	return c.words < other.words
+}
+
+// segment splits run into words: of all the ways to split it into words
+// of the dictionary and unknown clusters, it takes the one covering the
+// most characters with dictionary words and, among those, making the
+// fewest words. Depending on lang, neighbouring unknown clusters are then
+// joined into one word (see segmenter).
+func (d *segmentDict) segment(run []rune, lang string) []string {
+	n := len(run)
+	best := make([]segmentCost, n+1)
+	from := make([]int, n+1) // Start of the last word of the best split up to here
+	known := make([]bool, n+1)
+	reached := make([]bool, n+1)
+	reached[0] = true
+	relax := func(start, end int, cost segmentCost, isKnown bool) {
+		if !reached[end] || cost.less(best[end]) {
+			best[end], from[end], known[end], reached[end] = cost, start, isKnown, true
+		}
+	}
+
+	for i := 0; i < n; i++ {
+		if !reached[i] {
+			continue
+		}
+		for l := 1; l <= d.maxLen && i+l <= n; l++ {
+			if d.words[string(run[i:i+l])] && canBreak(run, i+l) {
+				relax(i, i+l, segmentCost{best[i].unknown, best[i].words + 1}, true)
+			}
+		}
+		next := i + 1
+		for !canBreak(run, next) {
+			next++
+		}
+		relax(i, next, segmentCost{best[i].unknown + next - i, best[i].words + 1}, false)
+	}
+
+	// Walk back from the end to find the words in reverse
+	type token struct {
+		start, end int
+		known      bool
+	}
+	var tokens []token
+	for end := n; end > 0; end = from[end] {
+		tokens = append(tokens, token{from[end], end, known[end]})
+	}
+
+	// Then hand them out in order, joining the unknown clusters that go
+	// together
+	var words []string
+	for k := len(tokens) - 1; k >= 0; k-- {
+		t := tokens[k]
+		for !t.known && k > 0 && !tokens[k-1].known && joinUnknown(run[t.start], run[tokens[k-1].start], lang) {
+			k--
+			t.end = tokens[k].end
+		}
+		words = append(words, string(run[t.start:t.end]))
+	}
+	return words
+}
+
+// joinUnknown reports whether an unknown cluster starting with b belongs to
+// the word of the unknown cluster before it, which starts with a.
+func joinUnknown(a, b rune, lang string) bool {
+	switch lang {
+	case segmentJapanese:
+		return segmentClass(a) == segmentClass(b)
+	case segmentThai:
+		return true
+	}
+	return false
+}
+
+// canBreak reports whether a word may end before run[i]: not before a
+// combining mark, and in Thai not inside a syllable cluster.
+func canBreak(run []rune, i int) bool {
+	if i == 0 || i >= len(run) {
+		return true
+	}
+	r, prev := run[i], run[i-1]
+	switch {
+	case unicode.IsMark(r):
+		return false
+	case prev >= 0x0E40 && prev <= 0x0E44:
+		return false // After a leading vowel (เ แ โ ใ ไ)
+	case r == 0x0E30 || r == 0x0E32 || r == 0x0E33 || r == 0x0E45 || r == 0x0E46:
+		return false // Before a following vowel (ะ า ำ ๅ) or the repetition mark ๆ
+	}
+	return true
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+// segmented splits text into words the way -segment lang does.
+func segmented(lang, text string, dict *segmentDict) []string {
+	var words []string
+	var word []rune
+	s := newSegmenter(lang, dict, func(r rune) {
+		word = append(word, r)
+	}, func() {
+		if len(word) > 0 {
+			words = append(words, string(word))
+			word = word[:0]
+		}
+	})
+	isWord := wordRuneFunc(Flags{Segment: lang})
+	for _, r := range text {
+		s.add(r, isWord(r))
+	}
+	s.flush()
+	s.end()
+	return words
+}
+
+func TestSegment(t *testing.T) {
+	tests := []struct {
+		lang, text string
+		want       []string
+	}{
+		// Dictionary words; other Han characters are words of their own
+		{"zh", "我们是中国人", []string{"我们", "是", "中国人"}},
+		{"zh", "今天天气很好", []string{"今天", "天气", "很", "好"}},
+		{"zh", "我喜欢学习中文。", []string{"我", "喜欢", "学习", "中文"}},
+		{"zh", "iPhone很好用", []string{"iPhone", "很", "好用"}},
+
+		// Particles and verb forms split off; unknown kanji stay together,
+		// and so does katakana
+		{"ja", "私は日本語を勉強しています", []string{"私", "は", "日本語", "を", "勉強", "しています"}},
+		{"ja", "東京に行きます", []string{"東京", "に", "行きます"}},
+		{"ja", "コーヒーを飲みます", []string{"コーヒー", "を", "飲みます"}},
+		{"ja", "量子力学を", []string{"量子力学", "を"}},
+
+		// Thai has no spaces between words; unknown text is one word, and
+		// no word ends inside a syllable
+		{"th", "ผมชอบกินข้าว", []string{"ผม", "ชอบ", "กิน", "ข้าว"}},
+		{"th", "สวัสดีครับ", []string{"สวัสดี", "ครับ"}},
+		{"th", "วันนี้อากาศร้อนมาก", []string{"วันนี้", "อากาศ", "ร้อน", "มาก"}},
+		{"th", "ภาษาไทยง่ายมาก", []string{"ภาษาไทย", "ง่าย", "มาก"}},
+		{"th", "ผมชอบกล้วยไข่", []string{"ผม", "ชอบ", "กล้วยไข่"}},
+
+		// Korean is split by spaces and punctuation only
+		{"ko", "안녕하세요, 세계", []string{"안녕하세요", "세계"}},
+	}
+	for _, tt := range tests {
+		if got := segmented(tt.lang, tt.text, nil); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("-segment %s of %q = %q, want %q", tt.lang, tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestSegmentDict(t *testing.T) {
+	dict := newSegmentDict("th")
+	dict.add(segmentWords["th"])
+	dict.add("กล้วย ไข่")
+	if got, want := segmented("th", "ผมชอบกล้วยไข่", dict), []string{"ผม", "ชอบ", "กล้วย", "ไข่"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("-segment th with -segment-dict = %q, want %q", got, want)
+	}
+
+	// A dictionary for another language is not used
+	if got, want := segmented("zh", "我们", dict), []string{"我们"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("-segment zh with a th dictionary = %q, want %q", got, want)
+	}
+}
+
+// TestSegmentBreaks checks that no split puts a break inside a Thai
+// syllable cluster.
+func TestSegmentBreaks(t *testing.T) {
+	for _, text := range []string{"เกี๊ยวกุ้ง", "ไก่ทอด", "แมวดำ", "น้ำแข็งใส"} {
+		for _, word := range segmented("th", text, nil) {
+			first := []rune(word)[0]
+			last := []rune(word)[len([]rune(word))-1]
+			if unicode.IsMark(first) || strings.ContainsRune("ะาำๅๆ", first) || (last >= 0x0E40 && last <= 0x0E44) {
+				t.Errorf("-segment th split %q inside a cluster, at %q", text, word)
+			}
+		}
+	}
+}
+
+// TestSegmentCount checks the word counts of whole inputs, with words cut
+// off by the end of a chunk and by the end of the input.
+func TestSegmentCount(t *testing.T) {
+	tests := []struct {
+		lang, text string
+		words      int64
+	}{
+		{"zh", "我们是中国人。今天天气很好\n", 7},
+		{"ja", "私は日本語を勉強しています", 6},
+		{"th", "ผมชอบกินข้าว สวัสดีครับ", 6},
+		{"ko", "안녕하세요 세계", 2},
+	}
+	for _, tt := range tests {
+		flags := Flags{Segment: tt.lang, MinWordLen: 1}
+		for _, chunk := range []int{1, 4, 1 << 16} {
+			c := newCounter(flags)
+			data := []byte(tt.text)
+			for len(data) > 0 {
+				n := chunk
+				if n > len(data) {
+					n = len(data)
+				}
+				c.Feed(data[:n])
+				data = data[n:]
+			}
+			if got := c.Result().(Counts).Words; got != tt.words {
+				t.Errorf("-segment %s of %q in chunks of %d: %d words, want %d", tt.lang, tt.text, chunk, got, tt.words)
+			}
+		}
+	}
+}
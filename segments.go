@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// segmentReader yields one segment of a -split-on input: the lines up to
+// the next sentinel line, which itself belongs to no segment, or up to the
+// end of the input. next moves on to the following segment.
+type segmentReader struct {
+	src      *bufio.Reader
+	sentinel []byte
+
+	out   []byte // Text of the current line not yet returned
+	long  bool   // In a line longer than the buffer, which is no sentinel
+	ended bool   // Has the current segment reached its sentinel?
+	err   error  // Error of the underlying reader, io.EOF at its end
+}
+
+func newSegmentReader(src io.Reader, sentinel string) *segmentReader {
+	return &segmentReader{src: bufio.NewReaderSize(src, bufferSize), sentinel: []byte(sentinel)}
+}
+
+func (s *segmentReader) Read(p []byte) (int, error) {
+	for len(s.out) == 0 {
+		if s.ended {
+			return 0, io.EOF
+		}
+		if s.err != nil {
+			return 0, s.err
+		}
+		s.readLine()
+	}
+	n := copy(p, s.out)
+	s.out = s.out[n:]
+	return n, nil
+}
+
+// readLine reads the next line, or the next buffer of a long one, into out,
+// unless it is a sentinel line.
+func (s *segmentReader) readLine() {
+	line, err := s.src.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		s.out, s.long = line, true // Passed on in pieces
+		return
+	}
+	if err != nil {
+		s.err = err
+	}
+	if !s.long && len(line) > 0 {
+		text := bytes.TrimSuffix(bytes.TrimSuffix(line, []byte("\n")), []byte("\r"))
+		if bytes.Equal(text, s.sentinel) {
+			s.ended = true
+			return
+		}
+	}
+	s.long = false
+	s.out = line
+}
+
+// next starts the following segment, reporting whether there is one. Input
+// ending right after a sentinel has no segment after it.
+func (s *segmentReader) next() bool {
+	// Skip what counting left unread, e.g. past a -line-range
+	for !s.ended && s.err == nil {
+		s.readLine()
+	}
+	s.out = nil
+	if !s.ended {
+		return false
+	}
+	s.ended = false
+	_, err := s.src.Peek(1)
+	return err == nil
+}
+
+// segmentName is the name segment i (from 1) is reported under.
+func segmentName(i int) string {
+	return fmt.Sprintf("(segment %d)", i)
+}
+
+// countSegments counts each segment of reader separately for -split-on,
+// handing the counts to report under the segment's name. It stops at the
+// first segment that cannot be counted.
+func countSegments(reader io.Reader, flags Flags, report func(counts Counts, filename string)) error {
+	segments := newSegmentReader(reader, flags.SplitOn)
+	for i := 1; ; i++ {
+		counts, err := countOpened(segments, segmentName(i), flags)
+		if err != nil {
+			return fmt.Errorf("%s: %w", segmentName(i), err)
+		}
+		report(counts, segmentName(i))
+		if !segments.next() {
+			return nil
+		}
+	}
+}
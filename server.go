@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// serverExcluded lists the options -server cannot be combined with, neither
+// at startup nor in a command: they read inputs other than the named file,
+// print output of their own, or only make sense across several results.
+// Above all, a client must not be able to run commands or write files on
+// the server's behalf, so every option that starts a process (-exec,
+// -pipe-through, -clipboard, -verify-against-wc) or writes a file
+// (-annotate, -checkpoint, -state-file, -tee) is among them.
+var serverExcluded = map[string]bool{
+	"annotate": true, "ascii-only": true, "baseline": true, "bytes-total": true,
+	"checkpoint": true, "clipboard": true, "compare": true, "dedup": true, "exec": true,
+	"detect-encoding": true, "dir-summary": true, "dry-run": true, "fd": true, "has-nul": true,
+	"hide-empty": true, "j": true, "json-errors": true, "json-stream-array": true, "merge": true,
+	"merge-label": true, "normalize": true, "pipe-through": true, "progress": true, "prometheus": true, "r": true,
+	"resume": true, "sort-by-name": true, "state-file": true, "tee": true,
+	"verbose": true, "verify-against-wc": true, "watch-dir": true, "watch-interval": true,
+}
+
+// serverStartupOnly lists the options that are read once when the server
+// starts and therefore cannot be changed by a command.
+var serverStartupOnly = map[string]bool{
+	"patterns": true, "report-empty-files": true, "segment-dict": true, "split-on": true, "list-empty": true, "server": true, "stopwords": true,
+}
+
+// checkServerFlags reports the first option set on fs that -server does not
+// allow.
+func checkServerFlags(fs *flag.FlagSet, command bool) error {
+	var err error
+	fs.Visit(func(f *flag.Flag) {
+		if err != nil {
+			return
+		}
+		if serverExcluded[f.Name] {
+			err = fmt.Errorf("-%s cannot be used with -server", f.Name)
+		} else if command && serverStartupOnly[f.Name] {
+			err = fmt.Errorf("-%s can only be given when the server starts", f.Name)
+		}
+	})
+	return err
+}
+
+// serve runs the -server protocol, which lets a client count many files
+// without paying the process startup cost for each of them.
+//
+// Each line read from in is a command: the name of a file, optionally
+// followed by options as on the command line, separated by blanks. A name
+// or option containing blanks can be written as a double-quoted Go string
+// literal. The options apply on top of those the server was started with,
+// to this command only; blank lines are ignored.
+//
+// Each command is answered, in order, by a status line. "ok N" is followed
+// by the N lines of output for the file (the counts line and any report
+// lines below it, or the JSON object with -json); "error MESSAGE" stands
+// alone. At the end of in, serve returns once the last command has been
+// answered.
+func serve(in io.Reader, out io.Writer, base Flags) error {
+	w := bufio.NewWriter(out)
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, bufferSize), 1<<20)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		lines, err := serveCommand(line, base)
+		if err != nil {
+			fmt.Fprintf(w, "error %s\n", strings.ReplaceAll(err.Error(), "\n", " "))
+		} else {
+			fmt.Fprintf(w, "ok %d\n", len(lines))
+			for _, l := range lines {
+				fmt.Fprintln(w, l)
+			}
+		}
+		// Answer right away: the client is waiting for it before sending more
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// serveCommand counts the file named by one -server command and returns the
+// output lines for it.
+func serveCommand(line string, base Flags) ([]string, error) {
+	words, err := splitCommand(line)
+	if err != nil {
+		return nil, err
+	}
+	filename := words[0]
+	if filename == "-" {
+		return nil, errors.New("standard input carries the commands and cannot be counted")
+	}
+
+	// Parse the command's options into a copy of the startup options. Defining
+	// the flags resets their targets to the defaults, hence the copy after it.
+	var flags Flags
+	fs := flag.NewFlagSet("command", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	defineFlags(fs, &flags)
+	flags = base
+	if err := fs.Parse(words[1:]); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected argument %q after the options", fs.Arg(0))
+	}
+	if err := checkServerFlags(fs, true); err != nil {
+		return nil, err
+	}
+	if err := flags.validate(); err != nil {
+		return nil, err
+	}
+	flags.applyDefaults()
+
+	counts, err := countFile(filename, flags)
+	if err != nil {
+		return nil, err
+	}
+	name := displayName(filename, flags)
+
+	if flags.JSON {
+		var data []byte
+		if flags.JSONPretty {
+			data, err = json.MarshalIndent(toJSONCounts(counts, flags, name), "", "  ")
+		} else {
+			data, err = json.Marshal(toJSONCounts(counts, flags, name))
+		}
+		if err != nil {
+			return nil, err
+		}
+		return strings.Split(string(data), "\n"), nil
+	}
+
+	var lines []string
+	if flags.Prose {
+		lines = append(lines, formatProse(counts, flags, proseSubject(name)))
+	} else if flags.KV {
+		lines = append(lines, formatKVLine(counts, flags, name))
+	} else {
+		if flags.Headers {
+			lines = append(lines, formatHeader(flags))
+		}
+		lines = append(lines, formatOutput(counts, flags, name))
+	}
+	return append(lines, formatDetails(counts, flags)...), nil
+}
+
+// splitCommand splits a -server command into blank-separated words, decoding
+// double-quoted words as Go string literals. The result is never empty for a
+// non-blank line.
+func splitCommand(line string) ([]string, error) {
+	var words []string
+	for {
+		line = strings.TrimLeft(line, " \t")
+		if line == "" {
+			return words, nil
+		}
+
+		if line[0] != '"' {
+			end := strings.IndexAny(line, " \t")
+			if end < 0 {
+				end = len(line)
+			}
+			words = append(words, line[:end])
+			line = line[end:]
+			continue
+		}
+
+		// Find the closing quote, stepping over escaped characters
+		end := 1
+		for end < len(line) && line[end] != '"' {
+			if line[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		if end >= len(line) {
+			return nil, errors.New("unterminated quoted string")
+		}
+		word, err := strconv.Unquote(line[:end+1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid quoted string %s", line[:end+1])
+		}
+		words = append(words, word)
+		line = line[end+1:]
+	}
+}
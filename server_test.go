@@ -0,0 +1,27 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestServeCommandRejectsSideEffects checks that a -server client cannot
+// start a process or write a file through the options of a command.
+func TestServeCommandRejectsSideEffects(t *testing.T) {
+	commands := []string{
+		"x -pipe-through=true",
+		"x -exec=true",
+		"x -clipboard",
+		"x -verify-against-wc",
+		"x -annotate=#",
+		"x -checkpoint=cp.json",
+		"x -state-file=state.json",
+		"x -tee=copy.txt",
+	}
+	for _, command := range commands {
+		_, err := serveCommand(command, Flags{})
+		if err == nil || !strings.Contains(err.Error(), "cannot be used with -server") {
+			t.Errorf("serveCommand(%q): got error %v, want a -server refusal", command, err)
+		}
+	}
+}
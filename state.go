@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// incrementalState implements -state-file: it remembers, per input file,
+// the byte offset up to which the file has already been counted, so that
+// the next run only counts what was appended since. It is safe for use by
+// the worker pool.
+type incrementalState struct {
+	path string
+
+	mu      sync.Mutex
+	offsets map[string]int64 // Absolute path -> bytes already counted
+}
+
+// loadState reads the state file at path. A missing file is an empty state.
+func loadState(path string) (*incrementalState, error) {
+	s := &incrementalState{path: path, offsets: make(map[string]int64)}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.offsets); err != nil {
+		return nil, fmt.Errorf("%s: invalid state file: %w", path, err)
+	}
+	return s, nil
+}
+
+// begin returns the byte range of filename that has not been counted yet,
+// as an offset and the file's current size. Counting stops at that size,
+// so that appends made while counting are left for the next run. A file
+// that shrank since the last run is assumed to have been rotated and is
+// counted from the start again.
+func (s *incrementalState) begin(filename string) (offset, size int64, err error) {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return 0, 0, err
+	}
+	key, err := filepath.Abs(filename)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	s.mu.Lock()
+	offset = s.offsets[key]
+	s.mu.Unlock()
+	if info.Size() < offset {
+		offset = 0
+	}
+	return offset, info.Size(), nil
+}
+
+// commit records that filename has been counted up to size.
+func (s *incrementalState) commit(filename string, size int64) {
+	key, err := filepath.Abs(filename)
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	s.offsets[key] = size
+	s.mu.Unlock()
+}
+
+// save writes the state back to its file, via a temporary file and rename
+// so that an interrupted run never leaves a truncated state behind.
+func (s *incrementalState) save() error {
+	s.mu.Lock()
+	data, err := json.MarshalIndent(s.offsets, "", "  ")
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, append(data, '\n'))
+}
+
+// writeFileAtomic replaces the file at path with data by writing a
+// temporary file next to it and renaming that over path.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".gowc-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
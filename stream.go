@@ -0,0 +1,38 @@
+package main
+
+import "gowc/stream"
+
+// The counting state machine can be driven by stream.Count, for snapshots
+// of its Counts while an input is being counted.
+var _ stream.Counter = (*counter)(nil)
+
+// Snapshot implements stream.Counter; it returns the Counts so far, as a
+// copy that shares no maps or other state with the counter. They are the
+// running counts. A word is counted as soon as it starts, unless
+// -min-word-len delays it to the word's end; the filtered words of
+// -stopwords and the capped ones of -max-word-cap are only counted at the
+// end of each word, too. A line is counted at its terminator. Summaries made at the end of an input, such as the -fields or
+// -readability ones, only appear in the final counts.
+func (c *counter) Snapshot() interface{} {
+	var counts Counts
+	counts.Merge(c.counts) // Cannot overflow, starting from zero
+	if c.graphemes != nil {
+		counts.Graphemes = c.graphemes.count
+	}
+	if c.substr != nil {
+		counts.Substr = c.substr.count
+	}
+	if c.patterns != nil {
+		counts.Patterns = append([]int64(nil), c.patterns.counts...)
+	}
+	if c.matches != nil {
+		counts.Matches = c.matches.count
+	}
+	if c.flags.RecordLength > 0 {
+		counts.Lines = recordCount(counts.Bytes, c.flags.RecordLength, false) // Complete ones so far
+	}
+	if c.dupes != nil {
+		counts.Repeats, counts.LongestRun = c.dupes.repeats, c.dupes.longestRun
+	}
+	return counts
+}
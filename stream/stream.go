@@ -0,0 +1,127 @@
+// Package stream counts an input in the background, delivering snapshots
+// of the counts so far while it goes, e.g. for a live dashboard: the
+// library counterpart of gowc's -progress.
+//
+// It drives any Counter; gowc's own counting state machine is one, whose
+// snapshots and result are its Counts value.
+package stream
+
+import (
+	"fmt"
+	"io"
+
+	"gowc/counters"
+)
+
+// bufferSize is the most Count reads at a time.
+const bufferSize = 64 * 1024
+
+// Counter is a counters.Counter whose counts so far can be taken while it
+// is still being fed.
+type Counter interface {
+	counters.Counter
+
+	// Snapshot returns a copy of the counts so far, which the receiver is
+	// free to keep: it shares no maps or other state with the counter.
+	Snapshot() interface{}
+}
+
+// Stream delivers the counts of an input while it is being counted; see
+// Count.
+type Stream struct {
+	// C receives the snapshots, and last the result. It is closed once
+	// the input is exhausted, counting failed or Stop was called.
+	C <-chan interface{}
+
+	err  error
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Count feeds r to c in a goroutine of its own, sending c's snapshot on
+// the returned stream's channel every time another every bytes have been
+// processed, and then c's result, which is exactly what feeding it r at
+// once would give.
+//
+// The channel is unbuffered, so counting waits for each snapshot to be
+// received: the caller must either drain the channel or call Stop. Stop
+// does not interrupt a Read that is blocked on r, so to abandon a stream
+// waiting for input, close r as well, if it is an io.Closer.
+func Count(r io.Reader, every int64, c Counter) *Stream {
+	ch := make(chan interface{})
+	s := &Stream{C: ch, stop: make(chan struct{}), done: make(chan struct{})}
+	go func() {
+		defer close(s.done)
+		defer close(ch)
+		s.err = s.run(r, every, c, ch)
+	}()
+	return s
+}
+
+// Err returns the error that ended counting, if any. It waits for the
+// counting goroutine to finish, so call it after the channel was closed.
+// A stream ended by Stop has no error.
+func (s *Stream) Err() error {
+	<-s.done
+	return s.err
+}
+
+// Stop ends counting before the input is exhausted and closes the channel,
+// discarding a snapshot waiting to be received. It may be called more than
+// once, and also after counting completed.
+func (s *Stream) Stop() {
+	select {
+	case <-s.stop:
+	default:
+		close(s.stop)
+	}
+	<-s.done
+}
+
+// run feeds r to c, sending the snapshots and the result on ch. It returns
+// early, without an error, if the stream is stopped.
+func (s *Stream) run(r io.Reader, every int64, c Counter, ch chan<- interface{}) error {
+	if every < 1 {
+		return fmt.Errorf("invalid snapshot interval: %d", every)
+	}
+
+	// Reading at most every bytes at a time makes the snapshots fall on
+	// multiples of every, short reads aside
+	size := int64(bufferSize)
+	if every < size {
+		size = every
+	}
+	buf := make([]byte, size)
+	var read int64
+	next := every
+
+	send := func(v interface{}) bool {
+		select {
+		case ch <- v:
+			return true
+		case <-s.stop:
+			return false
+		}
+	}
+	for {
+		n, err := r.Read(buf)
+		c.Feed(buf[:n])
+		read += int64(n)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("error reading input: %w", err)
+		}
+		if read >= next {
+			if !send(c.Snapshot()) {
+				return nil
+			}
+			for next <= read {
+				next += every
+			}
+		}
+	}
+	send(c.Result())
+	return nil
+}
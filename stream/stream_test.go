@@ -0,0 +1,92 @@
+package stream
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// progress counts bytes and lines, snapshotting both.
+type progress struct{ bytes, lines int }
+
+func (p *progress) Feed(buf []byte) {
+	p.bytes += len(buf)
+	p.lines += strings.Count(string(buf), "\n")
+}
+
+func (p *progress) Result() interface{}   { return *p }
+func (p *progress) Snapshot() interface{} { return *p }
+
+// endless reads as many newlines as are asked for, forever.
+type endless struct{}
+
+func (endless) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = '\n'
+	}
+	return len(p), nil
+}
+
+func TestCountSnapshots(t *testing.T) {
+	s := Count(strings.NewReader("ab\ncd\nef\ng"), 4, &progress{})
+	var got []interface{}
+	for v := range s.C {
+		got = append(got, v)
+	}
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+	want := []interface{}{progress{4, 1}, progress{8, 2}, progress{10, 3}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("received %v, want %v", got, want)
+	}
+
+	// Short reads make the snapshots fall on the first read past each
+	// multiple of every
+	s = Count(iotest.HalfReader(strings.NewReader("abcdefghij")), 3, &progress{})
+	got = got[:0]
+	for v := range s.C {
+		got = append(got, v.(progress).bytes)
+	}
+	if want := []interface{}{4, 6, 10, 10}; !reflect.DeepEqual(got, want) {
+		t.Errorf("received bytes %v, want %v", got, want)
+	}
+}
+
+func TestCountStop(t *testing.T) {
+	s := Count(endless{}, 1024, &progress{})
+	if first := (<-s.C).(progress); first.bytes != 1024 || first.lines != 1024 {
+		t.Errorf("first snapshot %v, want {1024 1024}", first)
+	}
+	s.Stop()
+	if v, ok := <-s.C; ok {
+		t.Errorf("received %v after Stop", v)
+	}
+	if err := s.Err(); err != nil {
+		t.Errorf("Err() = %v after Stop, want nil", err)
+	}
+	s.Stop() // Stopping again is harmless
+}
+
+func TestCountErr(t *testing.T) {
+	boom := errors.New("boom")
+	s := Count(io.MultiReader(strings.NewReader("abc"), iotest.ErrReader(boom)), 1024, &progress{})
+	for v := range s.C {
+		t.Errorf("received %v from a failed input", v)
+	}
+	if err := s.Err(); !errors.Is(err, boom) {
+		t.Errorf("Err() = %v, want %v", err, boom)
+	}
+	s.Stop() // Stopping after the stream ended is harmless
+
+	s = Count(strings.NewReader("abc"), 0, &progress{})
+	for v := range s.C {
+		t.Errorf("received %v with an invalid interval", v)
+	}
+	if s.Err() == nil {
+		t.Error("Err() = nil with an interval of 0")
+	}
+}
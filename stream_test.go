@@ -0,0 +1,48 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"gowc/stream"
+)
+
+func TestCounterSnapshots(t *testing.T) {
+	const text = "one two\nthree four five\nsix"
+	flags := Flags{ShowLines: true, ShowWords: true, ShowChars: true, ShowBytes: true, MinWordLen: 1}
+	s := stream.Count(strings.NewReader(text), 8, newCounter(flags))
+	var received []Counts
+	for v := range s.C {
+		received = append(received, v.(Counts))
+	}
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Words and lines are counted as they start and end
+	want := []Counts{
+		{Lines: 1, Words: 2, Bytes: 8, Chars: 8},
+		{Lines: 1, Words: 4, Bytes: 16, Chars: 16},
+		{Lines: 2, Words: 5, Bytes: 24, Chars: 24},
+	}
+	if len(received) != len(want)+1 {
+		t.Fatalf("received %d counts, want %d snapshots and the result", len(received), len(want))
+	}
+	for i, w := range want {
+		got := received[i]
+		if got.Lines != w.Lines || got.Words != w.Words || got.Bytes != w.Bytes || got.Chars != w.Chars {
+			t.Errorf("snapshot %d: lines %d, words %d, bytes %d, chars %d; want %d, %d, %d, %d",
+				i, got.Lines, got.Words, got.Bytes, got.Chars, w.Lines, w.Words, w.Bytes, w.Chars)
+		}
+	}
+
+	// The result is what counting the input at once gives
+	all, err := count(strings.NewReader(text), flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := received[len(received)-1]
+	if last.Lines != all.Lines || last.Words != all.Words || last.Bytes != all.Bytes || last.Chars != all.Chars {
+		t.Errorf("result %d %d %d %d, want %d %d %d %d", last.Lines, last.Words, last.Bytes, last.Chars, all.Lines, all.Words, all.Bytes, all.Chars)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import "bytes"
+
+// substrCounter counts the occurrences of a byte string for -count-substr.
+// An occurrence may straddle two chunks, so the last len(needle)-1 bytes of
+// each chunk are kept and searched again together with the next one. next
+// is the absolute offset at which the next occurrence may start: one past
+// the start of the previous one with -overlapping, past its end otherwise.
+type substrCounter struct {
+	needle      []byte
+	overlapping bool
+
+	tail   []byte // End of the input seen so far, too short to hold an occurrence
+	offset int64  // Absolute offset of tail[0]
+	next   int64
+	count  int64
+}
+
+func newSubstrCounter(needle string, overlapping bool) *substrCounter {
+	return &substrCounter{needle: []byte(needle), overlapping: overlapping}
+}
+
+func (s *substrCounter) feed(buf []byte) {
+	data := append(s.tail, buf...)
+	pos := 0
+	if skip := s.next - s.offset; skip > 0 {
+		pos = int(skip)
+	}
+	for pos <= len(data)-len(s.needle) {
+		i := bytes.Index(data[pos:], s.needle)
+		if i < 0 {
+			break
+		}
+		s.count++
+		start := pos + i
+		if s.overlapping {
+			pos = start + 1
+		} else {
+			pos = start + len(s.needle)
+		}
+		s.next = s.offset + int64(pos)
+	}
+
+	// Keep just enough for an occurrence that starts here and ends in the next chunk
+	keep := len(s.needle) - 1
+	if keep > len(data) {
+		keep = len(data)
+	}
+	s.offset += int64(len(data) - keep)
+	s.tail = append(data[:0], data[len(data)-keep:]...)
+}
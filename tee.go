@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// teeWriter is the -tee target. Every input is written to it as it is read,
+// so it ends up holding the inputs concatenated in the order they were
+// counted.
+type teeWriter struct {
+	path string
+	file *os.File
+}
+
+// createTee creates (or truncates) the -tee target at path.
+func createTee(path string) (*teeWriter, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &teeWriter{path: path, file: file}, nil
+}
+
+// Write implements io.Writer. Its errors are teeWriteErrors, so that a
+// failure to save the data can be told apart from a failure to read it.
+func (t *teeWriter) Write(p []byte) (int, error) {
+	n, err := t.file.Write(p)
+	if err != nil {
+		return n, &teeWriteError{path: t.path, err: err}
+	}
+	return n, nil
+}
+
+// Close flushes the target to disk and closes it.
+func (t *teeWriter) Close() error {
+	err := t.file.Sync()
+	if cerr := t.file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return &teeWriteError{path: t.path, err: err}
+	}
+	return nil
+}
+
+// teeWriteError is an error writing to the -tee target.
+type teeWriteError struct {
+	path string
+	err  error
+}
+
+func (e *teeWriteError) Error() string {
+	return fmt.Sprintf("error writing to %s: %v", e.path, e.err)
+}
+
+func (e *teeWriteError) Unwrap() error {
+	return e.err
+}
+
+// teeInput copies everything read from reader to the -tee target, if any.
+func teeInput(reader io.Reader, flags Flags) io.Reader {
+	if flags.tee == nil {
+		return reader
+	}
+	return io.TeeReader(reader, flags.tee)
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Kinds of malformed UTF-8 told apart by -utf8-report, indexing
+// UTF8Report.Errors.
+const (
+	utf8InvalidStart           = iota // A byte that never occurs in UTF-8 (C0, C1, F5-FF)
+	utf8UnexpectedContinuation        // A continuation byte outside any sequence
+	utf8Incomplete                    // A sequence cut short by a byte that does not continue it
+	utf8OutOfRange                    // An overlong encoding, a surrogate, or beyond U+10FFFF
+	utf8TruncatedAtEOF                // A sequence cut short by the end of the input
+	utf8ErrorKinds
+)
+
+// utf8ErrorNames are the names of the kinds of errors in reports.
+var utf8ErrorNames = [utf8ErrorKinds]string{
+	"invalid_start", "unexpected_continuation", "incomplete", "out_of_range", "truncated_at_eof",
+}
+
+// UTF8Error counts one kind of malformed UTF-8. First is the byte offset of
+// the first occurrence in the input, or -1; it describes a single input, so
+// Merge does not carry it over.
+type UTF8Error struct {
+	Count int64
+	First int64
+}
+
+// UTF8Report breaks the malformed UTF-8 in an input down by kind, for
+// -utf8-report. An invalid sequence counts once, at the offset it starts.
+type UTF8Report struct {
+	Errors [utf8ErrorKinds]UTF8Error
+}
+
+func newUTF8Report() *UTF8Report {
+	r := &UTF8Report{}
+	for i := range r.Errors {
+		r.Errors[i].First = -1
+	}
+	return r
+}
+
+// add records an error of the given kind at offset.
+func (r *UTF8Report) add(kind int, offset int64) {
+	e := &r.Errors[kind]
+	if e.Count == 0 {
+		e.First = offset
+	}
+	e.Count++
+}
+
+// Merge adds the error counts of other to r.
+func (r *UTF8Report) Merge(other *UTF8Report) {
+	for i := range r.Errors {
+		r.Errors[i].Count += other.Errors[i].Count
+	}
+}
+
+// Valid reports whether no error was found.
+func (r *UTF8Report) Valid() bool {
+	for _, e := range r.Errors {
+		if e.Count > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// utf8Checker validates UTF-8 byte by byte, following sequences across
+// chunk boundaries, and classifies what it finds wrong.
+type utf8Checker struct {
+	report *UTF8Report
+	offset int64 // Offset of the next byte
+
+	need     int   // Continuation bytes still expected in the current sequence
+	lo, hi   byte  // Range the next continuation byte must fall in
+	start    int64 // Offset of the current sequence's first byte
+	abortive bool  // Is the current sequence already known to be out of range?
+}
+
+func newUTF8Checker() *utf8Checker {
+	return &utf8Checker{report: newUTF8Report()}
+}
+
+// feed checks the next chunk of input.
+func (u *utf8Checker) feed(buf []byte) {
+	for _, b := range buf {
+		u.step(b)
+		u.offset++
+	}
+}
+
+func (u *utf8Checker) step(b byte) {
+	if u.need > 0 {
+		if b >= 0x80 && b <= 0xBF {
+			if (b < u.lo || b > u.hi) && !u.abortive {
+				// Only the first continuation byte has a narrower range; the
+				// rest of the sequence belongs to the same error
+				u.report.add(utf8OutOfRange, u.start)
+				u.abortive = true
+			}
+			u.need--
+			u.lo, u.hi = 0x80, 0xBF
+			return
+		}
+		if !u.abortive {
+			u.report.add(utf8Incomplete, u.start)
+		}
+		u.need = 0 // b starts afresh
+	}
+
+	u.start, u.abortive = u.offset, false
+	u.lo, u.hi = 0x80, 0xBF
+	switch {
+	case b < 0x80:
+	case b < 0xC0:
+		u.report.add(utf8UnexpectedContinuation, u.offset)
+	case b < 0xC2, b > 0xF4:
+		u.report.add(utf8InvalidStart, u.offset)
+	case b < 0xE0:
+		u.need = 1
+	case b < 0xF0:
+		u.need = 2
+		if b == 0xE0 {
+			u.lo = 0xA0 // Below is overlong
+		} else if b == 0xED {
+			u.hi = 0x9F // Above are surrogates
+		}
+	default:
+		u.need = 3
+		if b == 0xF0 {
+			u.lo = 0x90 // Below is overlong
+		} else if b == 0xF4 {
+			u.hi = 0x8F // Above is beyond U+10FFFF
+		}
+	}
+}
+
+// finish reports a sequence left open at the end of the input.
+func (u *utf8Checker) finish() {
+	if u.need > 0 && !u.abortive {
+		u.report.add(utf8TruncatedAtEOF, u.start)
+	}
+	u.need = 0
+}
+
+// formatUTF8Report describes a -utf8-report below a result, e.g.
+// "    utf-8: invalid_start=2 (first at 10) incomplete=1 (first at 3)".
+func formatUTF8Report(r *UTF8Report) string {
+	if r.Valid() {
+		return "    utf-8: valid"
+	}
+	parts := []string{"    utf-8:"}
+	for kind, e := range r.Errors {
+		if e.Count == 0 {
+			continue
+		}
+		part := fmt.Sprintf("%s=%d", utf8ErrorNames[kind], e.Count)
+		if e.First >= 0 {
+			part += fmt.Sprintf(" (first at %d)", e.First)
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, " ")
+}
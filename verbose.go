@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// describeModes returns the -verbose description of how the inputs are
+// going to be counted, one line per aspect that differs between modes.
+func describeModes(flags Flags) []string {
+	var lines []string
+	lines = append(lines, "columns: "+strings.Join(selectedNames(flags), " "))
+	if newCounter(flags).fastLines {
+		lines = append(lines, "lines: fast newline scan, nothing else needs the input byte by byte")
+	}
+	if flags.UnicodeSpace {
+		lines = append(lines, "words: split on Unicode white space in the decoded text")
+	}
+	if flags.Segment != "" {
+		lines = append(lines, fmt.Sprintf("words: segmented by the %s rules and dictionary, so counts are language-dependent", flags.Segment))
+	}
+	if flags.CountSubstr != "" {
+		mode := "non-overlapping"
+		if flags.Overlapping {
+			mode = "overlapping"
+		}
+		lines = append(lines, fmt.Sprintf("substring: counting %s occurrences of %q", mode, flags.CountSubstr))
+	}
+	if flags.CountColumn > 0 {
+		distinct := ""
+		if flags.Unique {
+			distinct = " and distinct"
+		}
+		lines = append(lines, fmt.Sprintf("column: counting non-empty%s values of field %d, split on %q", distinct, flags.CountColumn, flags.FieldSep))
+	}
+	return lines
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// hiddenFlags are left out of the usage message: they are development aids
+// rather than features.
+var hiddenFlags = map[string]bool{
+	"verify-against-wc": true,
+}
+
+// printDefaults prints the usage of the options defined on fs like
+// fs.PrintDefaults, without the hidden ones.
+func printDefaults(fs *flag.FlagSet) {
+	visible := flag.NewFlagSet(fs.Name(), flag.ContinueOnError)
+	visible.SetOutput(fs.Output())
+	fs.VisitAll(func(f *flag.Flag) {
+		if !hiddenFlags[f.Name] {
+			visible.Var(f.Value, f.Name, f.Usage)
+			// Var takes the current value for the default, which after
+			// a parse error may already be an argument
+			visible.Lookup(f.Name).DefValue = f.DefValue
+		}
+	})
+	visible.PrintDefaults()
+}
+
+// verifyAgainstWC counts each named file both itself and with the system
+// wc, in a UTF-8 locale, and reports every count on which the two
+// disagree, for -verify-against-wc. It returns the exit status: 1 if any
+// file differs or could not be checked.
+func verifyAgainstWC(filenames []string, flags Flags) int {
+	if len(filenames) == 0 {
+		fmt.Fprintf(os.Stderr, "%s: -verify-against-wc needs files to check (standard input cannot be read twice)\n", os.Args[0])
+		return 1
+	}
+	flags.ShowLines, flags.ShowWords, flags.ShowChars, flags.ShowBytes = true, true, true, true
+
+	status := 0
+	for _, filename := range filenames {
+		ours, err := countFile(filename, flags)
+		var theirs [4]int64
+		if err == nil {
+			theirs, err = countWithWC(filename)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s: %v\n", os.Args[0], filename, err)
+			status = 1
+			continue
+		}
+
+		var diffs []string
+		for i, name := range []string{"lines", "words", "chars", "bytes"} {
+			value := []int64{ours.Lines, ours.Words, ours.Chars, ours.Bytes}[i]
+			if value != theirs[i] {
+				diffs = append(diffs, fmt.Sprintf("%s %d, wc %d", name, value, theirs[i]))
+			}
+		}
+		if len(diffs) == 0 {
+			fmt.Printf("%s: same as wc\n", filename)
+			continue
+		}
+		fmt.Printf("%s: differs from wc: %s\n", filename, strings.Join(diffs, "; "))
+		status = 1
+	}
+	return status
+}
+
+// countWithWC runs "wc -l -w -m -c" on filename and returns its lines,
+// words, characters and bytes, which it prints in this order.
+func countWithWC(filename string) ([4]int64, error) {
+	var counts [4]int64
+	cmd := exec.Command("wc", "-l", "-w", "-m", "-c", filename)
+	cmd.Env = append(os.Environ(), "LC_ALL=C.UTF-8")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return counts, fmt.Errorf("wc failed: %s", msg)
+		}
+		return counts, fmt.Errorf("wc failed: %w", err)
+	}
+
+	fields := strings.Fields(string(out))
+	if len(fields) < len(counts) {
+		return counts, errors.New("unexpected wc output: " + strings.TrimSpace(string(out)))
+	}
+	for i := range counts {
+		if counts[i], err = strconv.ParseInt(fields[i], 10, 64); err != nil {
+			return counts, errors.New("unexpected wc output: " + strings.TrimSpace(string(out)))
+		}
+	}
+	return counts, nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// errStopWalk ends a walk early; it never escapes walkDir.
+var errStopWalk = errors.New("walk stopped")
+
+// walkDir calls visit for every regular file below root, in the lexical order
+// filepath.WalkDir visits them, until visit returns false. Errors on
+// individual entries are passed to onError and do not stop the walk.
+func walkDir(root string, visit func(path string) bool, onError func(path string, err error)) {
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			onError(path, err)
+			// A directory we cannot read is skipped, but the walk goes on
+			return nil
+		}
+		// Skip directories themselves as well as symlinks, devices, sockets and pipes
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if !visit(path) {
+			return errStopWalk
+		}
+		return nil
+	})
+}
+
+// displayName returns the name a file is printed under. With -relative-to the
+// path is shown relative to that base directory, then -trim-prefix strips its
+// prefix and -basename drops all but the last element.
+func displayName(path string, flags Flags) string {
+	if path == "" {
+		return path
+	}
+	name := relativeName(path, flags)
+	if hasPathPrefix(name, flags.TrimPrefix) {
+		// "src" trims "src/a.go" to "a.go" just like "src/" does
+		trimmed := strings.TrimLeft(name[len(flags.TrimPrefix):], string(filepath.Separator))
+		if trimmed != "" {
+			name = trimmed
+		}
+	}
+	if flags.Basename {
+		name = filepath.Base(name)
+	}
+	return name
+}
+
+// hasPathPrefix reports whether prefix is made of whole leading elements of
+// name: "d/sub" and "d/sub/" are prefixes of "d/sub/a.txt", but "d/su" is
+// not.
+func hasPathPrefix(name, prefix string) bool {
+	if prefix == "" || !strings.HasPrefix(name, prefix) {
+		return false
+	}
+	return len(name) == len(prefix) || os.IsPathSeparator(prefix[len(prefix)-1]) || os.IsPathSeparator(name[len(prefix)])
+}
+
+// relativeName applies -relative-to to path. Paths that lie outside the base
+// (or cannot be made relative) are shown as absolute paths instead.
+func relativeName(path string, flags Flags) string {
+	if flags.RelativeTo == "" {
+		return path
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	base, err := filepath.Abs(flags.RelativeTo)
+	if err != nil {
+		return abs
+	}
+	rel, err := filepath.Rel(base, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return abs
+	}
+	return rel
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestDisplayNameTrimPrefix(t *testing.T) {
+	sep := string(filepath.Separator)
+	name := "d" + sep + "sub" + sep + "a.txt"
+	tests := []struct {
+		prefix string
+		want   string
+	}{
+		{"d" + sep + "sub", "a.txt"},
+		{"d" + sep + "sub" + sep, "a.txt"},
+		{"d", "sub" + sep + "a.txt"},
+		{"d" + sep + "su", name}, // Not a whole element
+		{"d" + sep + "sub" + sep + "a", name},
+		{name, name}, // Trimming everything would leave no name
+		{"x", name},
+	}
+	for _, tt := range tests {
+		if got := displayName(name, Flags{TrimPrefix: tt.prefix}); got != tt.want {
+			t.Errorf("displayName(%q) with -trim-prefix %q = %q, want %q", name, tt.prefix, got, tt.want)
+		}
+	}
+}
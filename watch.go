@@ -0,0 +1,89 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// watchedFile is what watchDir remembers about a file between polls.
+type watchedFile struct {
+	size    int64
+	modTime time.Time
+	changed time.Time // When this size and modification time were first seen
+	counted bool      // Has this version of the file been counted yet?
+}
+
+// watchDir polls dir every interval and counts each regular file in it
+// that is created or modified, handing the result to finish. To avoid
+// counting a file while it is still being written, a change is only acted
+// upon once the file has stayed the same for a whole interval. Files present
+// when watching starts are not counted. watchDir returns on SIGINT or
+// SIGTERM, so the caller can finish its output.
+//
+// Polling, rather than filesystem notifications, keeps gowc free of
+// platform-specific code and external dependencies.
+func watchDir(dir string, interval time.Duration, flags Flags, finish func(filename string, counts Counts, err error)) error {
+	files := make(map[string]*watchedFile)
+	first := true
+
+	scan := func(now time.Time) error {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		present := make(map[string]bool, len(entries))
+		for _, entry := range entries {
+			if !entry.Type().IsRegular() {
+				continue
+			}
+			path := filepath.Join(dir, entry.Name())
+			info, err := entry.Info()
+			if err != nil {
+				continue // Removed since ReadDir, most likely
+			}
+			present[path] = true
+
+			f, ok := files[path]
+			if !ok || f.size != info.Size() || !f.modTime.Equal(info.ModTime()) {
+				files[path] = &watchedFile{size: info.Size(), modTime: info.ModTime(), changed: now, counted: first}
+				continue
+			}
+			if !f.counted && now.Sub(f.changed) >= interval {
+				f.counted = true
+				counts, err := countFile(path, flags)
+				finish(path, counts, err)
+			}
+		}
+		for path := range files {
+			if !present[path] {
+				delete(files, path)
+			}
+		}
+		first = false
+		return nil
+	}
+
+	if err := scan(time.Now()); err != nil {
+		return err
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(stop)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			if err := scan(now); err != nil {
+				return err
+			}
+		case <-stop:
+			return nil
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// trackWidth measures the display width of each line for -L and
+// -over-length: every character is one column wide, except that a tab
+// advances to the next multiple of -tab-width and a '\r' takes no space.
+// The length in bytes is measured alongside for -widest-line-bytes, again
+// leaving out '\r' so that CRLF lines measure the same as LF lines.
+func (c *counter) trackWidth(char byte, eol bool) {
+	if !eol && char != '\r' {
+		c.lineBytes++
+	}
+	switch {
+	case eol:
+		c.endWidth(c.counts.Lines) // The line was counted already
+	case char == '\t':
+		c.lineWidth += int64(c.flags.TabWidth) - c.lineWidth%int64(c.flags.TabWidth)
+	case char == '\r':
+	case char&0xC0 != 0x80: // Not a UTF-8 continuation byte
+		c.lineWidth++
+	}
+}
+
+// endWidth records the width of the line that just ended, the line
+// numbered line.
+func (c *counter) endWidth(line int64) {
+	if c.lineWidth > c.counts.MaxLine {
+		c.counts.MaxLine = c.lineWidth
+	}
+	if c.lineBytes > c.counts.MaxLineBytes {
+		c.counts.MaxLineBytes = c.lineBytes
+	}
+	if c.flags.OverLength > 0 && c.lineWidth > c.flags.OverLength {
+		c.counts.OverLength++
+		if c.flags.ListOverLength {
+			c.counts.OverLengthLines = append(c.counts.OverLengthLines, line)
+		}
+	}
+	if c.longest != nil {
+		c.longest.add(line, c.lineWidth)
+	}
+	c.lineWidth, c.lineBytes = 0, 0
+}
+
+// bytesPerLine returns the average number of bytes per line for
+// -bytes-per-line, terminators included; ok is false without any lines.
+func bytesPerLine(counts Counts) (perLine float64, ok bool) {
+	if counts.Lines == 0 {
+		return 0, false
+	}
+	return float64(counts.Bytes) / float64(counts.Lines), true
+}
+
+// formatOverLength lists the line numbers of the lines found by
+// -list-over-length.
+func formatOverLength(lines []int64) string {
+	numbers := make([]string, len(lines))
+	for i, line := range lines {
+		numbers[i] = strconv.FormatInt(line, 10)
+	}
+	return "    over length: lines " + strings.Join(numbers, " ")
+}
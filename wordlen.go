@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// WordLengths is the distribution of the average word length of lines,
+// for -word-length-stats: each line with words contributes the mean length
+// in characters of its whitespace-separated words. Mean and variance are
+// kept with Welford's algorithm, as for LineStats; the histogram groups
+// the lines by their average rounded down, so 4 holds the lines averaging
+// 4 to just under 5 characters.
+type WordLengths struct {
+	N    int64
+	Mean float64
+	M2   float64
+
+	Hist map[int64]int64
+}
+
+func newWordLengths() *WordLengths {
+	return &WordLengths{Hist: make(map[int64]int64)}
+}
+
+// Add records a line whose words average avg characters.
+func (w *WordLengths) Add(avg float64) {
+	w.N++
+	delta := avg - w.Mean
+	w.Mean += delta / float64(w.N)
+	w.M2 += delta * (avg - w.Mean)
+	w.Hist[int64(avg)]++
+}
+
+// Merge combines the lines of other into w.
+func (w *WordLengths) Merge(other *WordLengths) {
+	if other == nil || other.N == 0 {
+		return
+	}
+	n := w.N + other.N
+	delta := other.Mean - w.Mean
+	w.M2 += other.M2 + delta*delta*float64(w.N)*float64(other.N)/float64(n)
+	w.Mean += delta * float64(other.N) / float64(n)
+	w.N = n
+	for bucket, lines := range other.Hist {
+		w.Hist[bucket] += lines
+	}
+}
+
+// StdDev returns the population standard deviation of the line averages;
+// a small one means a consistent style.
+func (w *WordLengths) StdDev() float64 {
+	if w.N == 0 {
+		return 0
+	}
+	return math.Sqrt(w.M2 / float64(w.N))
+}
+
+// buckets returns the histogram buckets in increasing order.
+func (w *WordLengths) buckets() []int64 {
+	buckets := make([]int64, 0, len(w.Hist))
+	for bucket := range w.Hist {
+		buckets = append(buckets, bucket)
+	}
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i] < buckets[j] })
+	return buckets
+}
+
+// wordLengthTracker follows the words of the current line for
+// -word-length-stats. It splits words on whitespace bytes like the word
+// count does, and measures them in UTF-8 characters.
+type wordLengthTracker struct {
+	stats  *WordLengths
+	inWord bool
+	words  int64 // Words on the current line so far
+	chars  int64 // Characters in those words
+}
+
+func (t *wordLengthTracker) add(char byte, eol, isSpace bool) {
+	switch {
+	case eol:
+		t.endLine()
+	case isSpace:
+		t.inWord = false
+	default:
+		if !t.inWord {
+			t.words++
+			t.inWord = true
+		}
+		if char&0xC0 != 0x80 {
+			t.chars++
+		}
+	}
+}
+
+// endLine records the line just ended, unless it had no words.
+func (t *wordLengthTracker) endLine() {
+	if t.words > 0 {
+		t.stats.Add(float64(t.chars) / float64(t.words))
+	}
+	t.inWord, t.words, t.chars = false, 0, 0
+}
+
+// formatWordLengths formats the -word-length-stats line printed below the
+// counts: the summary, then the histogram as bucket=lines pairs.
+func formatWordLengths(w *WordLengths) string {
+	if w == nil || w.N == 0 {
+		return "    word length per line: no lines with words"
+	}
+	parts := []string{fmt.Sprintf("    word length per line: mean %.2f, stddev %.2f over %d lines;", w.Mean, w.StdDev(), w.N)}
+	for _, bucket := range w.buckets() {
+		parts = append(parts, fmt.Sprintf("%d=%d", bucket, w.Hist[bucket]))
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// trackDelims counts words for -no-collapse-delims, where each whitespace
+// character ends a field, like a comma in CSV: a line with n delimiters
+// has n+1 words, some of them possibly empty ("a  b" has three). Truly
+// empty lines have none, and a '\r' is taken to be part of a line
+// terminator rather than a delimiter.
+func (c *counter) trackDelims(char byte, eol, isSpace bool) {
+	switch {
+	case eol:
+		if c.delimLine {
+			c.counts.Words += c.delims + 1
+		}
+		c.delims, c.delimLine = 0, false
+	case char == '\r':
+	default:
+		c.delimLine = true
+		if isSpace {
+			c.delims++
+		}
+	}
+}
+
+// loadStopwords reads a stopword list with one word per line. Words are
+// case-folded, surrounding whitespace is ignored and blank lines are skipped.
+func loadStopwords(path string) (map[string]bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	stopwords := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		word := strings.TrimSpace(scanner.Text())
+		if word != "" {
+			stopwords[strings.ToLower(word)] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return stopwords, nil
+}
+
+// addWordRune appends the UTF-8 encoding of r to the word being captured.
+func (c *counter) addWordRune(r rune) {
+	if !c.belowWordCap() {
+		return
+	}
+	var buf [utf8.UTFMax]byte
+	n := utf8.EncodeRune(buf[:], r)
+	c.word = append(c.word, buf[:n]...)
+}
+
+// belowWordCap reports whether the character of the current word just
+// counted in wordLen is still to be captured under -max-word-cap.
+func (c *counter) belowWordCap() bool {
+	return c.flags.MaxWordCap == 0 || c.wordLen <= c.flags.MaxWordCap
+}
+
+// endWord handles the word just completed. A word shorter than
+// -min-word-len is ignored altogether. Otherwise, if word content is
+// captured, it is counted as a filtered word unless it is a stopword and,
+// when word frequencies were requested, tallied under its case-folded form.
+func (c *counter) endWord() {
+	long := c.wordLen >= c.flags.MinWordLen
+	capped := c.flags.MaxWordCap > 0 && c.wordLen > c.flags.MaxWordCap
+	c.wordLen = 0
+	if long && c.flags.MinWordLen > 1 {
+		c.counts.Words++
+	}
+	if !c.captureWords {
+		return
+	}
+
+	word := strings.ToLower(string(c.word))
+	c.word = c.word[:0]
+	if !long || c.flags.stopwords[word] {
+		return
+	}
+	c.counts.FilteredWords++
+	if capped {
+		c.counts.CappedWords++
+	}
+	if c.counts.Freq != nil {
+		c.counts.Freq[word]++
+	}
+	if c.counts.ApproxTop != nil {
+		c.counts.ApproxTop.Add(word)
+	}
+}
+
+// wordCount is one entry of a -top listing.
+type wordCount struct {
+	Word  string `json:"word"`
+	Count int64  `json:"count"`
+}
+
+// topWords returns the n most frequent words, most frequent first; words
+// that occur equally often are listed alphabetically. Words occurring fewer
+// than minCount times are left out (-min-frequency), so the list can be
+// shorter than n.
+func topWords(freq map[string]int64, n int, minCount int64) []wordCount {
+	words := make([]wordCount, 0, len(freq))
+	for word, count := range freq {
+		if count < minCount {
+			continue
+		}
+		words = append(words, wordCount{Word: word, Count: count})
+	}
+	sort.Slice(words, func(i, j int) bool {
+		if words[i].Count != words[j].Count {
+			return words[i].Count > words[j].Count
+		}
+		return words[i].Word < words[j].Word
+	})
+	if len(words) > n {
+		words = words[:n]
+	}
+	return words
+}
+
+// formatTopWords lists the -top words below a result, one per line.
+func formatTopWords(freq map[string]int64, n int, minCount int64) []string {
+	lines := []string{"    top words:"}
+	for _, w := range topWords(freq, n, minCount) {
+		lines = append(lines, fmt.Sprintf("    %*d %s", columnWidth, w.Count, w.Word))
+	}
+	return lines
+}